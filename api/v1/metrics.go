@@ -0,0 +1,95 @@
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Webhook decisions, exactly one is recorded per request when its outcome is known.
+const (
+	// DecisionPatched - Pod is patched to attach the NetworkAttachmentDefinition.
+	DecisionPatched = "patched"
+	// DecisionSkippedNotRequested - Pod does not request the NetworkAttachmentDefinition.
+	DecisionSkippedNotRequested = "skipped_not_requested"
+	// DecisionAudited - Pod would be patched but is allowed unchanged in Audit mode.
+	DecisionAudited = "audited"
+	// DecisionControlPlane - Linkerd control plane Pod is patched.
+	DecisionControlPlane = "control_plane"
+	// DecisionUIDAnnotated - Pod is patched and annotated with the proxy UID from the namespace UID range.
+	DecisionUIDAnnotated = "uid_annotated"
+	// DecisionUIDAnnotationMalformed - Pod is patched, but the namespace UID range annotation can not be parsed.
+	DecisionUIDAnnotationMalformed = "uid_annotation_malformed"
+	// DecisionRemoved - NetworkAttachmentDefinition is removed from a Pod which opted out of it.
+	DecisionRemoved = "removed"
+	// DecisionSkippedOutOfScope - Pod's namespace is out of the operator's namespace scope.
+	DecisionSkippedOutOfScope = "skipped_out_of_scope"
+	// DecisionSkippedTemplateMutated - Pod is created from a Pod template mutated by the workload webhook.
+	DecisionSkippedTemplateMutated = "skipped_template_mutated"
+	// DecisionSkippedTemplateUnchanged - Workload update does not change its Pod template.
+	DecisionSkippedTemplateUnchanged = "skipped_template_unchanged"
+	// DecisionSkippedTemplateImmutable - Workload update can not change its Pod template, e.g. of a Job.
+	DecisionSkippedTemplateImmutable = "skipped_template_immutable"
+)
+
+// Objects the webhook decisions are made for.
+const (
+	// webhookObjectPod - Pod handled by the Pod webhook.
+	webhookObjectPod = "pod"
+	// webhookObjectPodTemplate - workload Pod template handled by the workload webhook.
+	webhookObjectPodTemplate = "pod_template"
+)
+
+var (
+	webhookDecisionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "linkerd_multus",
+		Subsystem: "webhook",
+		Name:      "decisions_total",
+		Help: "Number of Pod and workload webhook requests by decision: patched, audited, skipped_not_requested, " +
+			"control_plane, uid_annotated, uid_annotation_malformed, removed, skipped_out_of_scope, " +
+			"skipped_template_mutated, skipped_template_unchanged or skipped_template_immutable, " +
+			"and object: pod or pod_template.",
+	}, []string{"decision", "object"})
+
+	webhookDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "linkerd_multus",
+		Subsystem: "webhook",
+		Name:      "duration_seconds",
+		Help:      "Pod and workload webhook request handling latency by response: allowed, patched, denied or errored.",
+		Buckets:   []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	}, []string{"response"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(webhookDecisionsTotal, webhookDuration)
+}
+
+// recordDecision records the decision of a webhook request, the failed requests have no decision.
+func recordDecision(decision, object string) {
+	if decision == "" {
+		return
+	}
+
+	webhookDecisionsTotal.WithLabelValues(decision, object).Inc()
+}
+
+// observeResponse records the webhook request latency by the response kind.
+func observeResponse(start time.Time, resp *admission.Response) {
+	var response string
+
+	switch {
+	case resp.Allowed && len(resp.Patches) > 0:
+		response = "patched"
+	case resp.Allowed:
+		response = "allowed"
+	case resp.Result != nil && resp.Result.Code == http.StatusForbidden:
+		response = "denied"
+	default:
+		response = "errored"
+	}
+
+	webhookDuration.WithLabelValues(response).Observe(time.Since(start).Seconds())
+}
@@ -23,12 +23,14 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
-	"strings"
+	"time"
 
 	k8s "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
+	"github.com/ErmakovDmitriy/linkerd-multus-attach-operator/settings"
 	"github.com/go-logr/logr"
 	pkgK8s "github.com/linkerd/linkerd2/pkg/k8s"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -49,13 +51,13 @@ var podCopyAnnotations = []string{
 //+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;versions=v1
 //+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch;versions=v1
 //+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;versions=v1
+//+kubebuilder:rbac:groups=multus.linkerd.io,resources=linkerdmultusconfigs,verbs=get;list;watch
 
 // PodAnnotator adds Multus annotation to a Pod to attach Linkerd CNI via Multus.
 type PodAnnotator struct {
-	controlPlaneNamespace string
-
-	namespaceAllowedUIDsAnnotation string
-	linkerdProxyUIDOffset          int
+	settings *settings.Loader
+	// scope is the namespace scope of the operator, the Pods in the other namespaces are not changed.
+	scope *settings.NamespaceScope
 
 	Client  client.Client
 	decoder *admission.Decoder
@@ -65,6 +67,76 @@ type PodAnnotator struct {
 // Checks if a Pod or its Namespace have "linkerd.io/multus" annotation and then
 // appends to "k8s.v1.cni.cncf.io/networks" annotations the linkerd-cni network.
 func (a *PodAnnotator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	start := time.Now()
+
+	resp, decision := a.handle(ctx, req)
+	observeResponse(start, &resp)
+	recordDecision(decision, webhookObjectPod)
+
+	return resp
+}
+
+// podMutation is the webhook decision to change a Pod or a workload Pod template.
+type podMutation struct {
+	// pod is the changed Pod.
+	pod *corev1.Pod
+	// decision is the webhookDecisionsTotal decision which is recorded when the change is applied.
+	decision string
+	// isAudit is true if the change must be only reported.
+	isAudit bool
+	// unchangedDecision is recorded instead of decision when the change is empty, if it is set.
+	unchangedDecision string
+	// netAttachRef is the NetworkAttachmentDefinition reference the Pod needs, empty if the Pod does not need it.
+	netAttachRef string
+}
+
+// replaceRecordedNetAttach removes the NetworkAttachmentDefinition reference the workload webhook recorded
+// in the PodTemplateNetworkAnnotation from the changed Pod, if it is not the one the Pod needs anymore,
+// e.g. after the NetworkAttachmentDefinition is renamed or the mesh is changed.
+// namespace is the Pod's namespace the references without a namespace are resolved in.
+func (m *podMutation) replaceRecordedNetAttach(namespace, recorded string) error {
+	if recorded == "" {
+		return nil
+	}
+
+	recordedNamespace, recordedName, err := settings.ParseNetworkAttachmentDefinitionReference(recorded)
+	if err != nil {
+		return err
+	}
+
+	if m.netAttachRef != "" {
+		refNamespace, refName, err := settings.ParseNetworkAttachmentDefinitionReference(m.netAttachRef)
+		if err != nil {
+			return err
+		}
+
+		if refName == recordedName && orNamespace(refNamespace, namespace) == orNamespace(recordedNamespace, namespace) {
+			return nil
+		}
+	}
+
+	pod, _, err := unpatchPod(m.pod, namespace, recorded)
+	if err != nil {
+		return err
+	}
+
+	m.pod = pod
+
+	return nil
+}
+
+// orNamespace returns the reference's namespace or, if it is empty, the Pod's namespace.
+func orNamespace(refNamespace, namespace string) string {
+	if refNamespace == "" {
+		return namespace
+	}
+
+	return refNamespace
+}
+
+// handle makes the webhook decision, Handle wraps it to measure the latency and record the decision.
+// The decision is empty if the request fails.
+func (a *PodAnnotator) handle(ctx context.Context, req admission.Request) (admission.Response, string) {
 	// log is for logging in this function.
 	var podlog = logf.FromContext(ctx).WithName("pod-webhook")
 
@@ -73,36 +145,114 @@ func (a *PodAnnotator) Handle(ctx context.Context, req admission.Request) admiss
 	if err != nil {
 		podlog.Error(err, "can not decode Pod")
 
-		return admission.Errored(http.StatusBadRequest, err)
+		return admission.Errored(http.StatusBadRequest, err), ""
 	}
 
 	podlog = podlog.WithValues("req_namespace", req.Namespace, "pod_generate_name", pod.GenerateName)
 	podlog.V(debugLogLevel).Info("Received request")
 
+	// A Pod created from a mutated Pod template is checked too, as the marker can be set by anyone
+	// and the settings or the Pod's opt-out may differ from the ones the template is mutated with.
+	isTemplateMutated := pod.GetAnnotations()[k8s.PodTemplateMutatedAnnotation] == k8s.PodTemplateMutatedValue
+
+	// The Pod as it is requested, mutate changes the decoded one.
+	original := pod.DeepCopy()
+
+	mutation, resp, decision := a.mutate(ctx, &podlog, req.Namespace, pod)
+	if mutation == nil {
+		return resp, decision
+	}
+
+	if err := mutation.replaceRecordedNetAttach(req.Namespace,
+		original.GetAnnotations()[k8s.PodTemplateNetworkAnnotation]); err != nil {
+		podlog.Info("Recorded NetworkAttachmentDefinition reference can not be removed, ignoring it",
+			"reason", err.Error())
+	}
+
+	if isTemplateMutated {
+		// As for a Pod template, only the templateAnnotations are changed, so the Pod is left unchanged,
+		// if the template has the networks and the proxy UID the Pod needs.
+		mutated := original.DeepCopy()
+		setTemplateAnnotations(&mutated.ObjectMeta, mutation.pod)
+
+		mutation.pod = mutated
+		mutation.unchangedDecision = DecisionSkippedTemplateMutated
+	}
+
+	return patchResponse(&podlog, req.Object.Raw, mutation.pod, mutation)
+}
+
+// mutate makes the webhook decision for a Pod or a workload Pod template in the namespace.
+// Returns the change or, if the Pod is not changed, nil, the response to return and its decision.
+func (a *PodAnnotator) mutate(ctx context.Context, podlog *logr.Logger, reqNamespace string,
+	pod *corev1.Pod) (*podMutation, admission.Response, string) {
+	if !a.scope.ContainsName(reqNamespace) {
+		return nil, outOfScopeResponse(podlog), DecisionSkippedOutOfScope
+	}
+
+	cfg, err := a.settings.Load(ctx)
+	if err != nil {
+		podlog.Error(err, "Can not load operator settings")
+
+		return nil, admission.Errored(http.StatusInternalServerError, err), ""
+	}
+
 	// Retrieve namespace annotations.
 	var namespace = &corev1.Namespace{}
 
-	if err := a.Client.Get(ctx, types.NamespacedName{Name: req.Namespace}, namespace); err != nil {
+	if err := a.Client.Get(ctx, types.NamespacedName{Name: reqNamespace}, namespace); err != nil {
+		// Only the Namespaces selected by the scope are cached.
+		if apierrors.IsNotFound(err) && a.scope.HasSelector() {
+			return nil, outOfScopeResponse(podlog), DecisionSkippedOutOfScope
+		}
+
 		podlog.Error(err, "Can not get namespace")
 
-		return admission.Errored(http.StatusInternalServerError, err)
+		return nil, admission.Errored(http.StatusInternalServerError, err), ""
+	}
+
+	if !a.scope.Contains(namespace) {
+		return nil, outOfScopeResponse(podlog), DecisionSkippedOutOfScope
 	}
 
 	nsAnnotations := namespace.GetAnnotations()
 
+	// The Pod as it is requested, without the annotations copied below.
+	original := pod.DeepCopy()
+
+	// The attach label is converted to the annotation, so the Pod and Namespace
+	// precedence rules are the same for both.
+	pod = copyAttachLabel(pod, cfg.AttachLabel)
+
 	// Annotate Pod with Namespace annotations.
-	pod = copyAnnotations(pod, nsAnnotations)
+	pod = copyAnnotations(pod, withAttachLabel(nsAnnotations, namespace.GetLabels(), cfg.AttachLabel))
 
 	// Do nothing, if Linkerd CNI is not requested.
 	var (
 		needNetAttach     bool
 		isControlPlanePod bool
+		// The mesh is selected by the namespace only, as the namespace has the NetworkAttachmentDefinition
+		// of its mesh, while the Pod's label is set by the Linkerd proxy injector.
+		mesh = cfg.MeshForNamespace(namespace)
 	)
 
+	if mesh == nil {
+		podlog.Info("Namespace references unknown Linkerd control plane, do not patch",
+			k8s.LinkerdControlPlaneNamespaceLabel, settings.ControlPlaneNamespaceReference(namespace.GetLabels(), namespace.GetAnnotations()))
+
+		return nil, admission.Allowed("Unknown Linkerd control plane"), ""
+	}
+
+	if reference := settings.ControlPlaneNamespaceReference(pod.GetLabels(), pod.GetAnnotations()); reference != "" &&
+		reference != mesh.LinkerdNamespace {
+		podlog.Info("Pod references another Linkerd control plane than its namespace, the namespace's one is used",
+			k8s.LinkerdControlPlaneNamespaceLabel, reference, "mesh", mesh.LinkerdNamespace)
+	}
+
 	if isMultusAnnotationRequested(pod) {
 		podlog.V(debugLogLevel).Info("Pod annotations do not request Multus NetworkAttachmentDefinition", "annotations", pod.GetAnnotations())
 		needNetAttach = true
-	} else if isControlPlane(pod, req.Namespace, a.controlPlaneNamespace) {
+	} else if isControlPlane(pod, reqNamespace, mesh.LinkerdNamespace) {
 		// Control plane Pods must be always processed by Linkerd CNI.
 		podlog.V(debugLogLevel).Info("Pod is a Linkerd control plane")
 
@@ -111,36 +261,142 @@ func (a *PodAnnotator) Handle(ctx context.Context, req admission.Request) admiss
 	}
 
 	if !needNetAttach {
-		podlog.V(debugLogLevel).Info("Multus NetworkAttachmentDefinition is not requested, do not patch")
+		// The networks annotation may be copied from another workload or namespace.
+		if isMultusAttachmentOptedOut(pod) {
+			return removeNetAttach(podlog, reqNamespace, pod, original, namespace, mesh, cfg.IsAudit())
+		}
+
+		return nil, notRequestedResponse(podlog), DecisionSkippedNotRequested
+	}
+
+	netAttachRef, err := getNetAttachReference(pod, namespace, mesh)
+	if err != nil {
+		podlog.Error(err, "Can not get NetworkAttachmentDefinition name")
 
-		return admission.Allowed("No Multus attachment requested")
+		return nil, admission.Denied(err.Error()), ""
 	}
 
 	// Mutate the fields in pod.
-	pod = patchPod(pod)
+	pod, err = patchPod(pod, reqNamespace, netAttachRef)
+	if err != nil {
+		podlog.Error(err, "Can not add NetworkAttachmentDefinition to Pod networks")
+
+		return nil, admission.Denied(err.Error()), ""
+	}
+
+	// A Pod gets the most specific decision: control_plane, the UID decision or patched.
+	var decision = DecisionPatched
 
 	// Add optional Openshift UID annotation if not set and the
 	// allowed range is defined by a namespace and NOT control plane
 	// namespace as they are special.
 	// Get the first UID and assign it as the proxy UID.
-	if !isControlPlanePod {
-		if containerUIDRange, ok := nsAnnotations[a.namespaceAllowedUIDsAnnotation]; ok {
-			podlog.V(debugLogLevel).Info("Pod's namespace has UID range annotation",
-				a.namespaceAllowedUIDsAnnotation, containerUIDRange)
-
-			pod = addOpenshiftProxyUID(&podlog, a.namespaceAllowedUIDsAnnotation, containerUIDRange, a.linkerdProxyUIDOffset, pod)
+	if isControlPlanePod {
+		decision = DecisionControlPlane
+	} else if containerUIDRange, ok := nsAnnotations[cfg.NamespaceUIDRangeAnnotation]; ok {
+		podlog.V(debugLogLevel).Info("Pod's namespace has UID range annotation",
+			cfg.NamespaceUIDRangeAnnotation, containerUIDRange)
+
+		var uidDecision string
+
+		pod, uidDecision = addOpenshiftProxyUID(podlog, cfg.NamespaceUIDRangeAnnotation, containerUIDRange,
+			cfg.LinkerdProxyUIDOffset, pod)
+		if uidDecision != "" {
+			decision = uidDecision
 		}
 	}
 
 	podlog.V(debugLogLevel).Info("Patches Pod annotations",
 		k8s.MultusNetworkAttachAnnotation, pod.GetAnnotations()[k8s.MultusNetworkAttachAnnotation])
 
-	marshaledPod, err := json.Marshal(pod)
+	return &podMutation{pod: pod, decision: decision, isAudit: cfg.IsAudit(), netAttachRef: netAttachRef},
+		admission.Response{}, ""
+}
+
+// patchResponse returns the patch from the raw requested object to the changed one and its decision.
+// In Audit mode the object is allowed unchanged with the DecisionAudited decision.
+func patchResponse(podlog *logr.Logger, raw []byte, changed interface{}, mutation *podMutation) (admission.Response, string) {
+	marshaled, err := json.Marshal(changed)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err), ""
+	}
+
+	resp := admission.PatchResponseFromRaw(raw, marshaled)
+
+	if len(resp.Patches) == 0 && mutation.unchangedDecision != "" {
+		podlog.V(debugLogLevel).Info("Object is already mutated, do not patch")
+
+		return resp, mutation.unchangedDecision
+	}
+
+	if mutation.isAudit {
+		return auditResponse(podlog, &resp), DecisionAudited
+	}
+
+	return resp, mutation.decision
+}
+
+// removeNetAttach removes the operator managed NetworkAttachmentDefinition from the networks annotation
+// of a Pod which opted out of Linkerd CNI, the other networks are kept. Only the networks annotation
+// of the original Pod is changed, the annotations copied from the Namespace are used for the decision only.
+func removeNetAttach(podlog *logr.Logger, reqNamespace string, pod, original *corev1.Pod,
+	namespace *corev1.Namespace, mesh *settings.Mesh, isAudit bool) (*podMutation, admission.Response, string) {
+	netAttachRef, err := getNetAttachReference(pod, namespace, mesh)
+	if err != nil {
+		// The Pod does not need the NetworkAttachmentDefinition, so it is not denied.
+		podlog.Info("Can not get NetworkAttachmentDefinition name to remove it", "reason", err.Error())
+
+		return nil, notRequestedResponse(podlog), DecisionSkippedNotRequested
+	}
+
+	unpatched, isRemoved, err := unpatchPod(original, reqNamespace, netAttachRef)
+	if err != nil {
+		// It is not the operator's network which breaks the annotation.
+		podlog.Info("Pod networks annotation can not be parsed, do not patch", "reason", err.Error())
+
+		return nil, notRequestedResponse(podlog), DecisionSkippedNotRequested
+	}
+
+	if !isRemoved {
+		return nil, notRequestedResponse(podlog), DecisionSkippedNotRequested
+	}
+
+	podlog.V(debugLogLevel).Info("Removes NetworkAttachmentDefinition from Pod which opted out of it",
+		"name", netAttachRef)
+
+	return &podMutation{pod: unpatched, decision: DecisionRemoved, isAudit: isAudit}, admission.Response{}, ""
+}
+
+// notRequestedResponse allows a Pod unchanged as it does not request the NetworkAttachmentDefinition.
+func notRequestedResponse(podlog *logr.Logger) admission.Response {
+	podlog.V(debugLogLevel).Info("Multus NetworkAttachmentDefinition is not requested, do not patch")
+
+	return admission.Allowed("No Multus attachment requested")
+}
+
+// outOfScopeResponse allows a Pod unchanged as its namespace is out of the operator's namespace scope.
+func outOfScopeResponse(podlog *logr.Logger) admission.Response {
+	podlog.V(debugLogLevel).Info("Namespace is out of the operator scope, do not patch")
+
+	return admission.Allowed("Namespace is out of the operator scope")
+}
+
+// auditResponse allows a Pod unchanged in Audit mode. The would-be patch
+// is put in the WebhookAuditPatchAnnotation audit annotation.
+func auditResponse(podlog *logr.Logger, resp *admission.Response) admission.Response {
+	patch, err := json.Marshal(resp.Patches)
 	if err != nil {
 		return admission.Errored(http.StatusInternalServerError, err)
 	}
 
-	return admission.PatchResponseFromRaw(req.Object.Raw, marshaledPod)
+	podlog.Info("Audit mode: Pod would be patched", "patch", string(patch))
+
+	auditResp := admission.Allowed("Audit mode")
+	auditResp.AuditAnnotations = map[string]string{
+		k8s.WebhookAuditPatchAnnotation: string(patch),
+	}
+
+	return auditResp
 }
 
 // InjectDecoder injects provided decoder to the WebHook instance.
@@ -150,15 +406,16 @@ func (a *PodAnnotator) InjectDecoder(d *admission.Decoder) error {
 }
 
 // SetupWebhookWithManager attaches PodAnnotator to a provided manager.
-func SetupWebhookWithManager(mgr ctrl.Manager, controlPlaneNamespace, namespaceAllowedUIDsAnnotation string, linkerdProxyUIDOffset int) {
+// The settings loader provides the Linkerd control plane namespace and the proxy UID settings,
+// the Pods out of the namespace scope are allowed unchanged, nil scope is the whole cluster.
+func SetupWebhookWithManager(mgr ctrl.Manager, settingsLoader *settings.Loader, scope *settings.NamespaceScope) {
 	mgr.GetWebhookServer().Register(
 		"/annotate-multus-v1-pod",
 		&webhook.Admission{
 			Handler: &PodAnnotator{
-				Client:                         mgr.GetClient(),
-				controlPlaneNamespace:          controlPlaneNamespace,
-				namespaceAllowedUIDsAnnotation: namespaceAllowedUIDsAnnotation,
-				linkerdProxyUIDOffset:          linkerdProxyUIDOffset,
+				Client:   mgr.GetClient(),
+				settings: settingsLoader,
+				scope:    scope,
 			},
 		},
 	)
@@ -166,42 +423,36 @@ func SetupWebhookWithManager(mgr ctrl.Manager, controlPlaneNamespace, namespaceA
 
 // Add the first allowed UID for Proxy UID based on Openshift namespace
 // annotation: openshift.io/sa.scc.uid-range={{ first ID }}/{{ pool size }}.
-func addOpenshiftProxyUID(podlog *logr.Logger, namespaceAllowedUIDsAnnotation, namespaceUIDRange string, proxyUIDOffset int, pod *corev1.Pod) *corev1.Pod {
+// Returns the UID decision or an empty string, if the Pod already has the proxy UID.
+func addOpenshiftProxyUID(podlog *logr.Logger, namespaceAllowedUIDsAnnotation, namespaceUIDRange string, proxyUIDOffset int, pod *corev1.Pod) (*corev1.Pod, string) {
 	// If the Pod has already configured value - leave it be.
 	if val, ok := pod.GetAnnotations()[k8s.LinkerdProxyUIDAnnotation]; ok && val != "" {
 		podlog.V(debugLogLevel).Info(
 			"Pod already has UID range annotation, not changing it",
 			"config.linkerd.io/proxy-uid", val)
 
-		return pod
+		return pod, ""
 	}
 
-	splIDRange := strings.Split(namespaceUIDRange, "/")
-	if len(splIDRange) != 2 { // The correct value is like "10000000/2000".
+	// The correct value is like "10000000/2000".
+	uid, err := k8s.ProxyUIDFromRange(namespaceUIDRange, proxyUIDOffset)
+	if err != nil {
 		// Incorrect value. The application assumes that something
 		// uses the same namespace annotation but for other purpose
-		// and ignores it.
+		// and ignores it. The controller ignores it too.
 		podlog.Info(
-			"Pod must be patched with proxy UID annotation but the namespace's range UID annotation does not conform to {{ first ID }}/{{ range }} format. Ignoring the annotation",
-			namespaceAllowedUIDsAnnotation, namespaceUIDRange)
-
-		return pod
-	}
+			"Pod must be patched with proxy UID annotation but the namespace's range UID annotation is not correct. Ignoring the annotation",
+			namespaceAllowedUIDsAnnotation, namespaceUIDRange, "reason", err.Error())
 
-	uid, err := strconv.Atoi(splIDRange[0])
-	if err != nil {
-		podlog.Error(err, "Pod's namespace UID range annotation is not correct, expected to have {{ first ID }}/{{ range }}, integers. Do not change the Pod",
-			namespaceAllowedUIDsAnnotation, namespaceUIDRange)
-
-		return pod
+		return pod, DecisionUIDAnnotationMalformed
 	}
 
-	newUIDValue := strconv.Itoa(uid + proxyUIDOffset)
+	newUIDValue := strconv.Itoa(uid)
 	pod.Annotations[k8s.LinkerdProxyUIDAnnotation] = newUIDValue
 
 	podlog.V(debugLogLevel).Info("Pod is patched with", k8s.LinkerdProxyUIDAnnotation, newUIDValue)
 
-	return pod
+	return pod, DecisionUIDAnnotated
 }
 
 // copyAnnotations copies podCopyAnnotations from a Pod's Namespace to the Pod.
@@ -227,6 +478,42 @@ func copyAnnotations(pod *corev1.Pod, nsAnnotations map[string]string) *corev1.P
 	return pod
 }
 
+// copyAttachLabel sets a Pod's MultusAttachAnnotation from the attach label,
+// if the annotation is not set. The annotation wins when both are set.
+func copyAttachLabel(pod *corev1.Pod, attachLabel string) *corev1.Pod {
+	val := k8s.MultusAttachValue(pod.GetAnnotations(), pod.GetLabels(), attachLabel)
+	if val == "" {
+		return pod
+	}
+
+	if pod.Annotations == nil {
+		pod.Annotations = make(map[string]string)
+	}
+
+	pod.Annotations[k8s.MultusAttachAnnotation] = val
+
+	return pod
+}
+
+// withAttachLabel returns a copy of a Namespace's annotations with the MultusAttachAnnotation
+// set from the attach label, if the annotation is not set.
+func withAttachLabel(nsAnnotations, nsLabels map[string]string, attachLabel string) map[string]string {
+	val := k8s.MultusAttachValue(nsAnnotations, nsLabels, attachLabel)
+	if val == "" {
+		return nsAnnotations
+	}
+
+	annotations := make(map[string]string, len(nsAnnotations)+1)
+
+	for key, annotation := range nsAnnotations {
+		annotations[key] = annotation
+	}
+
+	annotations[k8s.MultusAttachAnnotation] = val
+
+	return annotations
+}
+
 // isMultusAnnotationRequested checks if a Pod requires Linkerd CNI via Multus.
 func isMultusAnnotationRequested(pod *corev1.Pod) bool {
 	// Injection is explicitly requested.
@@ -241,6 +528,15 @@ func isMultusAnnotationRequested(pod *corev1.Pod) bool {
 	return false
 }
 
+// isMultusAttachmentOptedOut checks if a Pod explicitly disables Linkerd CNI via Multus
+// or the Linkerd proxy injection.
+func isMultusAttachmentOptedOut(pod *corev1.Pod) bool {
+	podAnnotations := pod.GetAnnotations()
+
+	return podAnnotations[k8s.MultusAttachAnnotation] == k8s.MultusAttachDisabled ||
+		podAnnotations[k8s.LinkerdInjectAnnotation] == pkgK8s.ProxyInjectDisabled
+}
+
 func isControlPlane(pod *corev1.Pod, reqNamespace, controlPlaneNamespace string) bool {
 	// Control plane Pods must be always processed by Linkerd CNI.
 	podLabels := pod.GetLabels()
@@ -258,25 +554,73 @@ func isControlPlane(pod *corev1.Pod, reqNamespace, controlPlaneNamespace string)
 	return false
 }
 
-// patchPod adds Linkerd CNI to a Pod's "k8s.v1.cni.cncf.io/networks" annotation.
-func patchPod(pod *corev1.Pod) *corev1.Pod {
-	podAnnotations := pod.GetAnnotations()
+// getNetAttachReference returns the NetworkAttachmentDefinition reference to add to a Pod.
+// The Pod may reference an existing NetworkAttachmentDefinition by PodNetworkAttachmentDefinitionAnnotation,
+// otherwise the operator managed one with the name resolved the same way as the controller does is used.
+func getNetAttachReference(pod *corev1.Pod, namespace *corev1.Namespace, mesh *settings.Mesh) (string, error) {
+	if reference := pod.GetAnnotations()[k8s.PodNetworkAttachmentDefinitionAnnotation]; reference != "" {
+		if _, _, err := settings.ParseNetworkAttachmentDefinitionReference(reference); err != nil {
+			return "", err
+		}
 
-	val, ok := podAnnotations[k8s.MultusNetworkAttachAnnotation]
-	if !ok || val == "" {
-		pod.Annotations[k8s.MultusNetworkAttachAnnotation] = k8s.MultusNetworkAttachmentDefinitionName
-	} else {
-		// Check that the linkerd-cni is not in the annotation's value yet.
-		nets := strings.Split(val, ",")
+		return reference, nil
+	}
 
-		for _, n := range nets {
-			if n == k8s.MultusNetworkAttachmentDefinitionName {
-				return pod
-			}
-		}
+	return mesh.NetworkAttachmentDefinitionNameFor(namespace)
+}
 
-		pod.Annotations[k8s.MultusNetworkAttachAnnotation] = val + "," + k8s.MultusNetworkAttachmentDefinitionName
+// patchPod adds Linkerd CNI to a Pod's "k8s.v1.cni.cncf.io/networks" annotation in the format
+// the annotation is written in. The annotation is not changed, if it already selects the
+// NetworkAttachmentDefinition in any form, e.g. "{{ namespace }}/{{ name }}" or "{{ name }}@{{ interface }}".
+// namespace is the Pod's namespace the references without a namespace are resolved in.
+func patchPod(pod *corev1.Pod, namespace, netAttachRef string) (*corev1.Pod, error) {
+	refNamespace, refName, err := settings.ParseNetworkAttachmentDefinitionReference(netAttachRef)
+	if err != nil {
+		return nil, err
 	}
 
-	return pod
+	networks, err := k8s.ParseNetworkSelection(pod.GetAnnotations()[k8s.MultusNetworkAttachAnnotation])
+	if err != nil {
+		return nil, err
+	}
+
+	if networks.Contains(namespace, refNamespace, refName) {
+		return pod, nil
+	}
+
+	networks.Add(refNamespace, refName)
+
+	if pod.Annotations == nil {
+		pod.Annotations = make(map[string]string)
+	}
+
+	pod.Annotations[k8s.MultusNetworkAttachAnnotation] = networks.String()
+
+	return pod, nil
+}
+
+// unpatchPod removes Linkerd CNI in any form from a Pod's "k8s.v1.cni.cncf.io/networks" annotation
+// and reports whether it was removed. The annotation is deleted if no other networks are left.
+func unpatchPod(pod *corev1.Pod, namespace, netAttachRef string) (*corev1.Pod, bool, error) {
+	refNamespace, refName, err := settings.ParseNetworkAttachmentDefinitionReference(netAttachRef)
+	if err != nil {
+		return nil, false, err
+	}
+
+	networks, err := k8s.ParseNetworkSelection(pod.GetAnnotations()[k8s.MultusNetworkAttachAnnotation])
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !networks.Remove(namespace, refNamespace, refName) {
+		return pod, false, nil
+	}
+
+	if value := networks.String(); value != "" {
+		pod.Annotations[k8s.MultusNetworkAttachAnnotation] = value
+	} else {
+		delete(pod.Annotations, k8s.MultusNetworkAttachAnnotation)
+	}
+
+	return pod, true, nil
 }
@@ -0,0 +1,328 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	multusv1alpha1 "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/api/v1alpha1"
+	k8s "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
+	"github.com/ErmakovDmitriy/linkerd-multus-attach-operator/settings"
+)
+
+// newNetworksPod returns a Pod with the networks annotation, an empty value means no annotation.
+func newNetworksPod(networks string) *corev1.Pod {
+	var pod = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}}}
+
+	if networks != "" {
+		pod.Annotations[k8s.MultusNetworkAttachAnnotation] = networks
+	}
+
+	return pod
+}
+
+func TestPatchPod(t *testing.T) {
+	tests := []struct {
+		name         string
+		networks     string
+		netAttachRef string
+		expected     string
+	}{
+		{"no networks", "", "linkerd-cni", "linkerd-cni"},
+		{"text list", "macvlan", "linkerd-cni", "macvlan,linkerd-cni"},
+		{"text list and a reference with namespace", "macvlan", "linkerd/linkerd-cni", "macvlan,linkerd/linkerd-cni"},
+		{"JSON list", `[{"name":"macvlan"}]`, "linkerd-cni", `[{"name":"macvlan"},{"name":"linkerd-cni"}]`},
+		{"JSON list and a reference with namespace", `[{"name":"macvlan"}]`, "linkerd/linkerd-cni",
+			`[{"name":"macvlan"},{"name":"linkerd-cni","namespace":"linkerd"}]`},
+		{"already added", "macvlan,linkerd-cni", "linkerd-cni", "macvlan,linkerd-cni"},
+		{"already added with the Pod's namespace", "app/linkerd-cni", "linkerd-cni", "app/linkerd-cni"},
+		{"already added with interface", "linkerd-cni@eth1", "linkerd-cni", "linkerd-cni@eth1"},
+		{"already added with spaces", "macvlan , linkerd-cni ", "linkerd-cni", "macvlan , linkerd-cni "},
+		{"already added to JSON list", `[{"name":"linkerd-cni","interface":"eth1"}]`, "linkerd-cni",
+			`[{"name":"linkerd-cni","interface":"eth1"}]`},
+		{"added from other namespace", "other/linkerd-cni", "linkerd-cni", "other/linkerd-cni,linkerd-cni"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod, err := patchPod(newNetworksPod(tt.networks), "app", tt.netAttachRef)
+			if err != nil {
+				t.Fatalf("patchPod() error = %v", err)
+			}
+
+			if got := pod.Annotations[k8s.MultusNetworkAttachAnnotation]; got != tt.expected {
+				t.Errorf("networks = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPatchPodRejectsMalformedAnnotation(t *testing.T) {
+	if _, err := patchPod(newNetworksPod("linkerd-cni@"), "app", "linkerd-cni"); err == nil {
+		t.Error("patchPod() error = nil, want the annotation parse error")
+	}
+}
+
+func TestUnpatchPod(t *testing.T) {
+	tests := []struct {
+		name            string
+		networks        string
+		netAttachRef    string
+		expectedRemoved bool
+		expected        string
+	}{
+		{"the only network", "linkerd-cni", "linkerd-cni", true, ""},
+		{"from text list", "macvlan,linkerd-cni", "linkerd-cni", true, "macvlan"},
+		{"with the Pod's namespace and interface", "app/linkerd-cni@eth1,macvlan", "linkerd-cni", true, "macvlan"},
+		{"by reference with namespace", "linkerd/linkerd-cni,macvlan", "linkerd/linkerd-cni", true, "macvlan"},
+		{"from JSON list", `[{"name":"macvlan"},{"name":"linkerd-cni"}]`, "linkerd-cni", true, `[{"name":"macvlan"}]`},
+		{"not added", "macvlan", "linkerd-cni", false, "macvlan"},
+		{"not the other namespace one", "other/linkerd-cni", "linkerd-cni", false, "other/linkerd-cni"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod, isRemoved, err := unpatchPod(newNetworksPod(tt.networks), "app", tt.netAttachRef)
+			if err != nil {
+				t.Fatalf("unpatchPod() error = %v", err)
+			}
+
+			if isRemoved != tt.expectedRemoved {
+				t.Errorf("unpatchPod() isRemoved = %v, want %v", isRemoved, tt.expectedRemoved)
+			}
+
+			got, ok := pod.Annotations[k8s.MultusNetworkAttachAnnotation]
+			if tt.expected == "" && ok {
+				t.Errorf("networks = %q, want no annotation", got)
+			} else if got != tt.expected {
+				t.Errorf("networks = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetNetAttachReference(t *testing.T) {
+	mesh := &settings.Mesh{LinkerdNamespace: "linkerd", NetworkAttachmentDefinitionName: "linkerd-cni-b"}
+
+	tests := []struct {
+		name           string
+		podAnnotations map[string]string
+		nsAnnotations  map[string]string
+		expected       string
+		expectedErr    bool
+	}{
+		{name: "mesh name", expected: "linkerd-cni-b"},
+		{name: "namespace annotation wins over the mesh name",
+			nsAnnotations: map[string]string{k8s.NetworkAttachmentDefinitionNameAnnotation: "app-cni"}, expected: "app-cni"},
+		{name: "pod annotation wins over the namespace annotation",
+			podAnnotations: map[string]string{k8s.PodNetworkAttachmentDefinitionAnnotation: "web/linkerd-cni"},
+			nsAnnotations:  map[string]string{k8s.NetworkAttachmentDefinitionNameAnnotation: "app-cni"},
+			expected:       "web/linkerd-cni"},
+		{name: "invalid pod annotation",
+			podAnnotations: map[string]string{k8s.PodNetworkAttachmentDefinitionAnnotation: "web/Linkerd_CNI"}, expectedErr: true},
+		{name: "invalid namespace annotation",
+			nsAnnotations: map[string]string{k8s.NetworkAttachmentDefinitionNameAnnotation: "app/cni"}, expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: tt.podAnnotations}}
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app", Annotations: tt.nsAnnotations}}
+
+			got, err := getNetAttachReference(pod, ns, mesh)
+			if tt.expectedErr != errors.Is(err, settings.ErrInvalidNetworkAttachmentDefinitionName) {
+				t.Fatalf("getNetAttachReference() error = %v, want error %v", err, tt.expectedErr)
+			}
+
+			if got != tt.expected {
+				t.Errorf("getNetAttachReference() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsMultusAttachmentOptedOut(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    bool
+	}{
+		{"no annotations", nil, false},
+		{"attachment enabled", map[string]string{k8s.MultusAttachAnnotation: k8s.MultusAttachEnabled}, false},
+		{"attachment disabled", map[string]string{k8s.MultusAttachAnnotation: k8s.MultusAttachDisabled}, true},
+		{"injection disabled", map[string]string{k8s.LinkerdInjectAnnotation: "disabled"}, true},
+		{"injection enabled", map[string]string{k8s.LinkerdInjectAnnotation: "enabled"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}}
+
+			if got := isMultusAttachmentOptedOut(pod); got != tt.expected {
+				t.Errorf("isMultusAttachmentOptedOut() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+// newTestPodAnnotator returns a PodAnnotator with the default settings which reads the objects.
+func newTestPodAnnotator(t *testing.T, objects ...runtime.Object) *PodAnnotator {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatalf("AddToScheme() error = %v", err)
+	}
+
+	if err := multusv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("AddToScheme() error = %v", err)
+	}
+
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatalf("NewDecoder() error = %v", err)
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build()
+
+	return &PodAnnotator{
+		Client:  c,
+		decoder: decoder,
+		settings: &settings.Loader{
+			Client:     c,
+			ConfigName: multusv1alpha1.LinkerdMultusConfigNameDefault,
+			Defaults:   settings.Settings{LinkerdNamespace: "linkerd"},
+		},
+	}
+}
+
+// expectedNetworksPath is the JSON patch path of the networks annotation.
+const expectedNetworksPath = "/metadata/annotations/k8s.v1.cni.cncf.io~1networks"
+
+func TestHandleTemplateMutatedPod(t *testing.T) {
+	// The namespace opts in, so its annotations are copied to the Pods without the marker.
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name: "app",
+		Annotations: map[string]string{
+			k8s.MultusAttachAnnotation:  k8s.MultusAttachEnabled,
+			k8s.LinkerdInjectAnnotation: "enabled",
+		},
+	}}
+
+	tests := []struct {
+		name             string
+		networks         string
+		recorded         string
+		expectedNetworks string
+		expectedDecision string
+	}{
+		{
+			name:             "template has the network",
+			networks:         k8s.MultusNetworkAttachmentDefinitionName,
+			expectedDecision: DecisionSkippedTemplateMutated,
+		},
+		{
+			name:             "template has another network",
+			networks:         "macvlan",
+			expectedNetworks: "macvlan," + k8s.MultusNetworkAttachmentDefinitionName,
+			expectedDecision: DecisionPatched,
+		},
+		{
+			name:             "template has the renamed network",
+			networks:         "linkerd-cni-old",
+			recorded:         "linkerd-cni-old",
+			expectedNetworks: k8s.MultusNetworkAttachmentDefinitionName,
+			expectedDecision: DecisionPatched,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := newNetworksPod(tt.networks)
+			pod.Annotations[k8s.PodTemplateMutatedAnnotation] = k8s.PodTemplateMutatedValue
+
+			if tt.recorded != "" {
+				pod.Annotations[k8s.PodTemplateNetworkAnnotation] = tt.recorded
+			}
+
+			raw, err := json.Marshal(pod)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+
+			resp, decision := newTestPodAnnotator(t, namespace).handle(context.Background(), admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Namespace: namespace.Name,
+					Operation: admissionv1.Create,
+					Object:    runtime.RawExtension{Raw: raw},
+				},
+			})
+
+			if !resp.Allowed || decision != tt.expectedDecision {
+				t.Fatalf("handle() = %v, %q, want allowed, %q", resp.Result, decision, tt.expectedDecision)
+			}
+
+			switch {
+			case tt.expectedNetworks == "" && len(resp.Patches) != 0:
+				t.Errorf("handle() patches = %v, want no patches", resp.Patches)
+			case tt.expectedNetworks != "" && (len(resp.Patches) != 1 ||
+				resp.Patches[0].Path != expectedNetworksPath || resp.Patches[0].Value != tt.expectedNetworks):
+				t.Errorf("handle() patches = %v, want only the networks %q", resp.Patches, tt.expectedNetworks)
+			}
+		})
+	}
+}
+
+func TestAttachLabel(t *testing.T) {
+	const attachLabel = "multus.linkerd.io/attach"
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		labels      map[string]string
+		attachLabel string
+		expected    string
+	}{
+		{"label only", nil, map[string]string{attachLabel: k8s.MultusAttachEnabled}, attachLabel, k8s.MultusAttachEnabled},
+		{"annotation only", map[string]string{k8s.MultusAttachAnnotation: k8s.MultusAttachEnabled}, nil, attachLabel,
+			k8s.MultusAttachEnabled},
+		{"label and annotation agree", map[string]string{k8s.MultusAttachAnnotation: k8s.MultusAttachEnabled},
+			map[string]string{attachLabel: k8s.MultusAttachEnabled}, attachLabel, k8s.MultusAttachEnabled},
+		{"annotation wins over the conflicting label", map[string]string{k8s.MultusAttachAnnotation: k8s.MultusAttachDisabled},
+			map[string]string{attachLabel: k8s.MultusAttachEnabled}, attachLabel, k8s.MultusAttachDisabled},
+		{"label is disabled", nil, map[string]string{attachLabel: k8s.MultusAttachEnabled}, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations, Labels: tt.labels}}
+
+			if got := copyAttachLabel(pod, tt.attachLabel).Annotations[k8s.MultusAttachAnnotation]; got != tt.expected {
+				t.Errorf("copyAttachLabel() annotation = %q, want %q", got, tt.expected)
+			}
+
+			if got := withAttachLabel(tt.annotations, tt.labels, tt.attachLabel)[k8s.MultusAttachAnnotation]; got != tt.expected {
+				t.Errorf("withAttachLabel() annotation = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+
+	t.Run("withAttachLabel does not change the Namespace annotations", func(t *testing.T) {
+		nsAnnotations := map[string]string{"app": "a"}
+
+		withAttachLabel(nsAnnotations, map[string]string{attachLabel: k8s.MultusAttachEnabled}, attachLabel)
+
+		if _, ok := nsAnnotations[k8s.MultusAttachAnnotation]; ok {
+			t.Errorf("withAttachLabel() changed the Namespace annotations to %v", nsAnnotations)
+		}
+	})
+}
@@ -25,7 +25,9 @@ import (
 	"testing"
 	"time"
 
+	multusv1alpha1 "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/api/v1alpha1"
 	"github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
+	"github.com/ErmakovDmitriy/linkerd-multus-attach-operator/settings"
 	. "github.com/onsi/ginkgo"
 	"github.com/onsi/ginkgo/reporters"
 	. "github.com/onsi/gomega"
@@ -105,7 +107,15 @@ var _ = BeforeSuite(func() {
 	})
 	Expect(err).NotTo(HaveOccurred())
 
-	SetupWebhookWithManager(mgr, "linkerd", k8s.NamespaceAllowedUIDRangeAnnotationDefault, k8s.LinkerdProxyUIDDefaultOffset)
+	SetupWebhookWithManager(mgr, &settings.Loader{
+		Client:     mgr.GetClient(),
+		ConfigName: multusv1alpha1.LinkerdMultusConfigNameDefault,
+		Defaults: settings.Settings{
+			LinkerdNamespace:            "linkerd",
+			NamespaceUIDRangeAnnotation: k8s.NamespaceAllowedUIDRangeAnnotationDefault,
+			LinkerdProxyUIDOffset:       k8s.LinkerdProxyUIDDefaultOffset,
+		},
+	}, nil)
 
 	//+kubebuilder:scaffold:webhook
 
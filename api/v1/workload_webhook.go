@@ -0,0 +1,224 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	k8s "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
+	"github.com/ErmakovDmitriy/linkerd-multus-attach-operator/settings"
+)
+
+// templateAnnotations are the Pod annotations the webhook decides on, only they and
+// the PodTemplateNetworkAnnotation are set in a Pod template.
+var templateAnnotations = []string{
+	k8s.MultusNetworkAttachAnnotation,
+	k8s.LinkerdProxyUIDAnnotation,
+}
+
+//nolint:lll
+//+kubebuilder:webhook:path=/annotate-multus-v1-workload,mutating=true,failurePolicy=ignore,sideEffects=None,groups=apps;batch,resources=deployments;statefulsets;daemonsets;jobs;cronjobs,verbs=create;update,versions=v1,name=workloads.multus.linkerd.io,admissionReviewVersions=v1
+
+// WorkloadAnnotator adds Multus annotation to the Pod template of a workload: a Deployment, StatefulSet,
+// DaemonSet, Job or CronJob, so the stored workload shows the networks its Pods get.
+// Its failures are ignored as the Pod webhook still handles the Pods of a not mutated template.
+type WorkloadAnnotator struct {
+	pods    *PodAnnotator
+	decoder *admission.Decoder
+}
+
+// Handle implements WebHook handler.
+// Makes the Pod webhook decision for the workload's Pod template and marks the template
+// with PodTemplateMutatedAnnotation. An update is mutated only if it changes a not yet mutated template
+// or a mutated template does not have the NetworkAttachmentDefinition its Pods need.
+func (a *WorkloadAnnotator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	start := time.Now()
+
+	resp, decision := a.handle(ctx, req)
+	observeResponse(start, &resp)
+	recordDecision(decision, webhookObjectPodTemplate)
+
+	return resp
+}
+
+// handle makes the webhook decision, Handle wraps it to measure the latency and record the decision.
+// The decision is empty if the request fails or the workload kind is not supported.
+func (a *WorkloadAnnotator) handle(ctx context.Context, req admission.Request) (admission.Response, string) {
+	var workloadlog = logf.FromContext(ctx).WithName("workload-webhook").WithValues(
+		"req_namespace", req.Namespace, "kind", req.Kind.Kind, "name", req.Name)
+
+	workload, template := newWorkload(req.Kind)
+	if workload == nil {
+		workloadlog.Info("Unsupported workload kind, do not patch")
+
+		return admission.Allowed("Unsupported workload kind"), ""
+	}
+
+	if err := a.decoder.Decode(req, workload); err != nil {
+		workloadlog.Error(err, "can not decode workload")
+
+		return admission.Errored(http.StatusBadRequest, err), ""
+	}
+
+	workloadlog.V(debugLogLevel).Info("Received request")
+
+	if req.Operation == admissionv1.Update {
+		decision, err := a.updateSkipDecision(req, template)
+		if err != nil {
+			workloadlog.Error(err, "can not decode old workload")
+
+			return admission.Errored(http.StatusBadRequest, err), ""
+		}
+
+		if decision != "" {
+			workloadlog.V(debugLogLevel).Info("Workload update does not need the Pod template mutation, do not patch",
+				"decision", decision)
+
+			return admission.Allowed("Pod template is not mutated on this update"), decision
+		}
+	}
+
+	// The template is handled as a Pod in the workload's namespace.
+	pod := &corev1.Pod{ObjectMeta: *template.ObjectMeta.DeepCopy()}
+	pod.Namespace = req.Namespace
+
+	mutation, resp, decision := a.pods.mutate(ctx, &workloadlog, req.Namespace, pod)
+	if mutation == nil {
+		return resp, decision
+	}
+
+	if err := mutation.replaceRecordedNetAttach(req.Namespace,
+		template.GetAnnotations()[k8s.PodTemplateNetworkAnnotation]); err != nil {
+		workloadlog.Info("Recorded NetworkAttachmentDefinition reference can not be removed, ignoring it",
+			"reason", err.Error())
+	}
+
+	// A mutated template is left unchanged, if it has the networks and the proxy UID its Pods need.
+	if template.GetAnnotations()[k8s.PodTemplateMutatedAnnotation] == k8s.PodTemplateMutatedValue {
+		mutation.unchangedDecision = DecisionSkippedTemplateMutated
+	}
+
+	applyPodMutation(template, mutation)
+
+	return patchResponse(&workloadlog, req.Object.Raw, workload, mutation)
+}
+
+// updateSkipDecision returns the decision to allow a workload update unchanged or an empty string,
+// if its Pod template must be checked. A Job Pod template is immutable, so it is mutated only on creation.
+// A mutated template is always checked, it is changed only if it does not have the NetworkAttachmentDefinition
+// its Pods need. A not mutated template is checked only if the update changes it, so an unrelated update
+// does not change the template and start a rollout.
+func (a *WorkloadAnnotator) updateSkipDecision(req admission.Request, template *corev1.PodTemplateSpec) (string, error) {
+	if req.Kind == jobKind {
+		return DecisionSkippedTemplateImmutable, nil
+	}
+
+	if template.GetAnnotations()[k8s.PodTemplateMutatedAnnotation] == k8s.PodTemplateMutatedValue {
+		return "", nil
+	}
+
+	oldWorkload, oldTemplate := newWorkload(req.Kind)
+
+	if err := a.decoder.DecodeRaw(req.OldObject, oldWorkload); err != nil {
+		return "", err
+	}
+
+	if equality.Semantic.DeepEqual(oldTemplate, template) {
+		return DecisionSkippedTemplateUnchanged, nil
+	}
+
+	return "", nil
+}
+
+// InjectDecoder injects provided decoder to the WebHook instance.
+func (a *WorkloadAnnotator) InjectDecoder(d *admission.Decoder) error {
+	a.decoder = d
+	return nil
+}
+
+// SetupWorkloadWebhookWithManager attaches WorkloadAnnotator to a provided manager.
+// The Pod templates are changed with the same settings and namespace scope as the Pods.
+func SetupWorkloadWebhookWithManager(mgr ctrl.Manager, settingsLoader *settings.Loader, scope *settings.NamespaceScope) {
+	mgr.GetWebhookServer().Register(
+		"/annotate-multus-v1-workload",
+		&webhook.Admission{
+			Handler: &WorkloadAnnotator{
+				pods: &PodAnnotator{
+					Client:   mgr.GetClient(),
+					settings: settingsLoader,
+					scope:    scope,
+				},
+			},
+		},
+	)
+}
+
+// jobKind is the kind of the Jobs, their Pod template is immutable.
+var jobKind = metav1.GroupVersionKind{Group: batchv1.GroupName, Version: "v1", Kind: "Job"}
+
+// newWorkload returns an empty workload of the kind and its Pod template or nil, if the kind is not supported.
+func newWorkload(kind metav1.GroupVersionKind) (client.Object, *corev1.PodTemplateSpec) {
+	switch kind {
+	case metav1.GroupVersionKind{Group: appsv1.GroupName, Version: "v1", Kind: "Deployment"}:
+		deployment := &appsv1.Deployment{}
+		return deployment, &deployment.Spec.Template
+	case metav1.GroupVersionKind{Group: appsv1.GroupName, Version: "v1", Kind: "StatefulSet"}:
+		statefulSet := &appsv1.StatefulSet{}
+		return statefulSet, &statefulSet.Spec.Template
+	case metav1.GroupVersionKind{Group: appsv1.GroupName, Version: "v1", Kind: "DaemonSet"}:
+		daemonSet := &appsv1.DaemonSet{}
+		return daemonSet, &daemonSet.Spec.Template
+	case jobKind:
+		job := &batchv1.Job{}
+		return job, &job.Spec.Template
+	case metav1.GroupVersionKind{Group: batchv1.GroupName, Version: "v1", Kind: "CronJob"}:
+		cronJob := &batchv1.CronJob{}
+		return cronJob, &cronJob.Spec.JobTemplate.Spec.Template
+	default:
+		return nil, nil
+	}
+}
+
+// applyPodMutation sets the templateAnnotations of the mutated Pod in the Pod template, records
+// the NetworkAttachmentDefinition reference the Pods need and marks the template as mutated.
+// The annotations copied from the Namespace for the decision are not set, so the template shows
+// only the operator's changes.
+func applyPodMutation(template *corev1.PodTemplateSpec, mutation *podMutation) {
+	setTemplateAnnotations(&template.ObjectMeta, mutation.pod)
+
+	if mutation.netAttachRef != "" {
+		template.Annotations[k8s.PodTemplateNetworkAnnotation] = mutation.netAttachRef
+	} else {
+		delete(template.Annotations, k8s.PodTemplateNetworkAnnotation)
+	}
+
+	template.Annotations[k8s.PodTemplateMutatedAnnotation] = k8s.PodTemplateMutatedValue
+}
+
+// setTemplateAnnotations sets the templateAnnotations of the mutated Pod in the object's metadata,
+// the other annotations are not changed.
+func setTemplateAnnotations(meta *metav1.ObjectMeta, pod *corev1.Pod) {
+	if meta.Annotations == nil {
+		meta.Annotations = make(map[string]string)
+	}
+
+	for _, key := range templateAnnotations {
+		if val, ok := pod.Annotations[key]; ok {
+			meta.Annotations[key] = val
+		} else {
+			delete(meta.Annotations, key)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	k8s "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
+)
+
+var deploymentKind = metav1.GroupVersionKind{Group: appsv1.GroupName, Version: "v1", Kind: "Deployment"}
+
+func TestNewWorkload(t *testing.T) {
+	tests := []struct {
+		kind     string
+		group    string
+		template func(client.Object) *corev1.PodTemplateSpec
+	}{
+		{"Deployment", appsv1.GroupName,
+			func(o client.Object) *corev1.PodTemplateSpec { return &o.(*appsv1.Deployment).Spec.Template }},
+		{"StatefulSet", appsv1.GroupName,
+			func(o client.Object) *corev1.PodTemplateSpec { return &o.(*appsv1.StatefulSet).Spec.Template }},
+		{"DaemonSet", appsv1.GroupName,
+			func(o client.Object) *corev1.PodTemplateSpec { return &o.(*appsv1.DaemonSet).Spec.Template }},
+		{"Job", batchv1.GroupName,
+			func(o client.Object) *corev1.PodTemplateSpec { return &o.(*batchv1.Job).Spec.Template }},
+		{"CronJob", batchv1.GroupName,
+			func(o client.Object) *corev1.PodTemplateSpec {
+				return &o.(*batchv1.CronJob).Spec.JobTemplate.Spec.Template
+			}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			workload, template := newWorkload(metav1.GroupVersionKind{Group: tt.group, Version: "v1", Kind: tt.kind})
+			if workload == nil {
+				t.Fatal("newWorkload() workload = nil")
+			}
+
+			if template != tt.template(workload) {
+				t.Error("newWorkload() template is not the workload's Pod template")
+			}
+		})
+	}
+
+	t.Run("ReplicaSet is not supported", func(t *testing.T) {
+		workload, template := newWorkload(metav1.GroupVersionKind{Group: appsv1.GroupName, Version: "v1", Kind: "ReplicaSet"})
+		if workload != nil || template != nil {
+			t.Errorf("newWorkload() = %v, %v, want nil", workload, template)
+		}
+	})
+}
+
+func TestApplyPodMutation(t *testing.T) {
+	tests := []struct {
+		name                string
+		templateAnnotations map[string]string
+		podAnnotations      map[string]string
+		netAttachRef        string
+		expected            map[string]string
+	}{
+		{
+			name: "added network and proxy UID",
+			podAnnotations: map[string]string{
+				k8s.MultusNetworkAttachAnnotation: "linkerd-cni",
+				k8s.LinkerdProxyUIDAnnotation:     "1000",
+				k8s.MultusAttachAnnotation:        k8s.MultusAttachEnabled,
+			},
+			netAttachRef: "linkerd-cni",
+			expected: map[string]string{
+				k8s.MultusNetworkAttachAnnotation: "linkerd-cni",
+				k8s.LinkerdProxyUIDAnnotation:     "1000",
+				k8s.PodTemplateNetworkAnnotation:  "linkerd-cni",
+				k8s.PodTemplateMutatedAnnotation:  k8s.PodTemplateMutatedValue,
+			},
+		},
+		{
+			name: "removed network",
+			templateAnnotations: map[string]string{
+				k8s.MultusNetworkAttachAnnotation: "linkerd-cni",
+				k8s.PodTemplateNetworkAnnotation:  "linkerd-cni",
+				k8s.MultusAttachAnnotation:        k8s.MultusAttachDisabled,
+			},
+			podAnnotations: map[string]string{
+				k8s.MultusAttachAnnotation: k8s.MultusAttachDisabled,
+			},
+			expected: map[string]string{
+				k8s.MultusAttachAnnotation:       k8s.MultusAttachDisabled,
+				k8s.PodTemplateMutatedAnnotation: k8s.PodTemplateMutatedValue,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := &corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Annotations: tt.templateAnnotations}}
+
+			applyPodMutation(template, &podMutation{
+				pod:          &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: tt.podAnnotations}},
+				netAttachRef: tt.netAttachRef,
+			})
+
+			if !equality.Semantic.DeepEqual(template.Annotations, tt.expected) {
+				t.Errorf("template annotations = %v, want %v", template.Annotations, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUpdateSkipDecision(t *testing.T) {
+	decoder, err := admission.NewDecoder(scheme.Scheme)
+	if err != nil {
+		t.Fatalf("NewDecoder() error = %v", err)
+	}
+
+	newDeployment := func(annotations map[string]string) *appsv1.Deployment {
+		deployment := &appsv1.Deployment{}
+		deployment.Spec.Template.Annotations = annotations
+
+		return deployment
+	}
+
+	raw := func(o runtime.Object) runtime.RawExtension {
+		data, err := json.Marshal(o)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+
+		return runtime.RawExtension{Raw: data}
+	}
+
+	tests := []struct {
+		name     string
+		kind     metav1.GroupVersionKind
+		old      *appsv1.Deployment
+		template map[string]string
+		expected string
+	}{
+		{
+			name:     "Job template is immutable",
+			kind:     jobKind,
+			expected: DecisionSkippedTemplateImmutable,
+		},
+		{
+			name:     "mutated template is checked even if it is not changed",
+			kind:     deploymentKind,
+			old:      newDeployment(map[string]string{k8s.PodTemplateMutatedAnnotation: k8s.PodTemplateMutatedValue}),
+			template: map[string]string{k8s.PodTemplateMutatedAnnotation: k8s.PodTemplateMutatedValue},
+		},
+		{
+			name:     "template is not changed",
+			kind:     deploymentKind,
+			old:      newDeployment(map[string]string{"app": "a"}),
+			template: map[string]string{"app": "a"},
+			expected: DecisionSkippedTemplateUnchanged,
+		},
+		{
+			name:     "template is changed",
+			kind:     deploymentKind,
+			old:      newDeployment(map[string]string{"app": "a"}),
+			template: map[string]string{"app": "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req = admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Kind:      tt.kind,
+				Operation: admissionv1.Update,
+			}}
+
+			if tt.old != nil {
+				req.OldObject = raw(tt.old)
+			}
+
+			a := &WorkloadAnnotator{decoder: decoder}
+
+			got, err := a.updateSkipDecision(req, &newDeployment(tt.template).Spec.Template)
+			if err != nil {
+				t.Fatalf("updateSkipDecision() error = %v", err)
+			}
+
+			if got != tt.expected {
+				t.Errorf("updateSkipDecision() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHandleMutatedTemplate(t *testing.T) {
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name: "app",
+		Annotations: map[string]string{
+			k8s.MultusAttachAnnotation:  k8s.MultusAttachEnabled,
+			k8s.LinkerdInjectAnnotation: "enabled",
+		},
+	}}
+
+	mutatedTemplate := func(networks, recorded string) map[string]string {
+		return map[string]string{
+			k8s.PodTemplateMutatedAnnotation:  k8s.PodTemplateMutatedValue,
+			k8s.MultusNetworkAttachAnnotation: networks,
+			k8s.PodTemplateNetworkAnnotation:  recorded,
+		}
+	}
+
+	tests := []struct {
+		name             string
+		template         map[string]string
+		expected         map[string]string
+		expectedDecision string
+	}{
+		{
+			name:             "template has the NetworkAttachmentDefinition",
+			template:         mutatedTemplate("macvlan,linkerd-cni", "linkerd-cni"),
+			expected:         mutatedTemplate("macvlan,linkerd-cni", "linkerd-cni"),
+			expectedDecision: DecisionSkippedTemplateMutated,
+		},
+		{
+			name:             "template has the NetworkAttachmentDefinition with the namespace",
+			template:         mutatedTemplate("app/linkerd-cni,macvlan", "linkerd-cni"),
+			expected:         mutatedTemplate("app/linkerd-cni,macvlan", "linkerd-cni"),
+			expectedDecision: DecisionSkippedTemplateMutated,
+		},
+		{
+			name:             "renamed NetworkAttachmentDefinition is replaced",
+			template:         mutatedTemplate("linkerd-cni-old,macvlan", "linkerd-cni-old"),
+			expected:         mutatedTemplate("macvlan,linkerd-cni", "linkerd-cni"),
+			expectedDecision: DecisionPatched,
+		},
+	}
+
+	decoder, err := admission.NewDecoder(scheme.Scheme)
+	if err != nil {
+		t.Fatalf("NewDecoder() error = %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{}
+			deployment.Spec.Template.Annotations = tt.template
+
+			raw, err := json.Marshal(deployment)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+
+			a := &WorkloadAnnotator{pods: newTestPodAnnotator(t, namespace), decoder: decoder}
+
+			resp, decision := a.handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Kind:      deploymentKind,
+				Namespace: namespace.Name,
+				Operation: admissionv1.Update,
+				Object:    runtime.RawExtension{Raw: raw},
+				OldObject: runtime.RawExtension{Raw: raw},
+			}})
+
+			if !resp.Allowed || decision != tt.expectedDecision {
+				t.Fatalf("handle() = %v, %q, want allowed, %q", resp.Result, decision, tt.expectedDecision)
+			}
+
+			expected := &appsv1.Deployment{}
+			expected.Spec.Template.Annotations = tt.expected
+
+			expectedResp := admission.PatchResponseFromRaw(raw, mustMarshal(t, expected))
+
+			// The patch operations order follows the annotations map order.
+			sort.Slice(resp.Patches, func(i, j int) bool { return resp.Patches[i].Path < resp.Patches[j].Path })
+			sort.Slice(expectedResp.Patches, func(i, j int) bool {
+				return expectedResp.Patches[i].Path < expectedResp.Patches[j].Path
+			})
+
+			if !equality.Semantic.DeepEqual(resp.Patches, expectedResp.Patches) {
+				t.Errorf("handle() patches = %v, want %v", resp.Patches, expectedResp.Patches)
+			}
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	return raw
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2022 ErmakovDmitriy.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// LinkerdMultusConfigNameDefault is the default name of the LinkerdMultusConfig
+// the operator reads its configuration from.
+const LinkerdMultusConfigNameDefault = "default"
+
+// ConditionTypeReady is a LinkerdMultusConfig condition which is True when
+// the Linkerd CNI configuration is loaded and can be propagated to namespaces.
+const ConditionTypeReady = "Ready"
+
+// Mode defines if the operator changes the cluster.
+// +kubebuilder:validation:Enum=Enforce;Audit
+type Mode string
+
+const (
+	// ModeEnforce - the controller manages NetworkAttachmentDefinitions and the webhook patches Pods.
+	ModeEnforce Mode = "Enforce"
+	// ModeAudit - the controller and the webhook only report the changes they would make.
+	ModeAudit Mode = "Audit"
+)
+
+// LinkerdMultusConfigSpec defines the operator configuration.
+// Empty fields are replaced with the operator's command-line flag values.
+type LinkerdMultusConfigSpec struct {
+	// CNINamespace is the namespace in which Linkerd CNI is installed.
+	// It is used to get the Linkerd CNI ConfigMap.
+	// +optional
+	CNINamespace string `json:"cniNamespace,omitempty"`
+
+	// CNIKubeconfigPath is the path on Kubernetes hosts where Linkerd CNI DaemonSet Pods put Kubeconfig.
+	// +optional
+	CNIKubeconfigPath string `json:"cniKubeconfigPath,omitempty"`
+
+	// LinkerdNamespace is the namespace in which Linkerd control plane is installed.
+	// +optional
+	LinkerdNamespace string `json:"linkerdNamespace,omitempty"`
+
+	// NamespaceUIDRangeAnnotation is the Namespace annotation which contains allowed container UID range
+	// in {{ first UID }}/{{ length }} format.
+	// +optional
+	NamespaceUIDRangeAnnotation string `json:"namespaceUIDRangeAnnotation,omitempty"`
+
+	// LinkerdProxyUIDOffset is added to the first allowed UID in a namespace to generate Linkerd proxy UID.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	LinkerdProxyUIDOffset *int32 `json:"linkerdProxyUIDOffset,omitempty"`
+
+	// NetworkAttachmentDefinitionName is the default name of the NetworkAttachmentDefinitions.
+	// A namespace overrides it with the "multus.linkerd.io/network-attachment-definition-name" annotation.
+	// +optional
+	NetworkAttachmentDefinitionName string `json:"networkAttachmentDefinitionName,omitempty"`
+
+	// AttachLabel is the Namespace and Pod label which requests Linkerd CNI attachment
+	// with "enabled" value the same way as the "linkerd.io/multus" annotation does.
+	// The annotation wins when both are set. Empty string disables the label.
+	// +optional
+	AttachLabel *string `json:"attachLabel,omitempty"`
+
+	// Mode is Enforce to apply the changes or Audit to only report them.
+	// In Audit mode the controller reports the NetworkAttachmentDefinition changes as Events and
+	// the webhook allows Pods unchanged with the would-be patch in the audit annotations.
+	// +optional
+	Mode Mode `json:"mode,omitempty"`
+
+	// CNIVersion is the CNI specification version of the NetworkAttachmentDefinitions.
+	// If empty, the version of the Linkerd CNI configuration is used.
+	// +kubebuilder:validation:Pattern=`^[0-9]+\.[0-9]+\.[0-9]+$`
+	// +optional
+	CNIVersion string `json:"cniVersion,omitempty"`
+
+	// CNISupportedVersions are the CNI specification versions the installed Linkerd CNI supports.
+	// A Linkerd CNI configuration version which is not in the list is downgraded to the newest older
+	// supported version. Defaults to the operator's "-cni-supported-versions" flag value.
+	// +optional
+	CNISupportedVersions []string `json:"cniSupportedVersions,omitempty"`
+
+	// Chain renders the NetworkAttachmentDefinitions as a CNI plugin chain (conflist) of
+	// Linkerd CNI and the given plugins. If empty, the NetworkAttachmentDefinitions contain only Linkerd CNI.
+	// +optional
+	Chain *PluginChain `json:"chain,omitempty"`
+
+	// Meshes are the Linkerd control planes in the cluster, each with its own Linkerd CNI.
+	// A namespace selects a mesh by the "linkerd.io/control-plane-ns" label or annotation,
+	// the first mesh is used when a namespace does not select any.
+	// If empty, the only mesh is defined by the cniNamespace, cniKubeconfigPath and linkerdNamespace fields.
+	// +optional
+	Meshes []MeshInstance `json:"meshes,omitempty"`
+}
+
+// MeshInstance is a Linkerd control plane with its Linkerd CNI installation.
+type MeshInstance struct {
+	// LinkerdNamespace is the namespace of the Linkerd control plane.
+	// +kubebuilder:validation:MinLength=1
+	LinkerdNamespace string `json:"linkerdNamespace"`
+
+	// CNINamespace is the namespace in which the mesh's Linkerd CNI is installed.
+	// Defaults to spec.cniNamespace.
+	// +optional
+	CNINamespace string `json:"cniNamespace,omitempty"`
+
+	// CNIKubeconfigPath is the path on Kubernetes hosts where the mesh's Linkerd CNI puts Kubeconfig.
+	// Defaults to spec.cniKubeconfigPath.
+	// +optional
+	CNIKubeconfigPath string `json:"cniKubeconfigPath,omitempty"`
+
+	// NetworkAttachmentDefinitionName is the name of the mesh's NetworkAttachmentDefinitions.
+	// Defaults to spec.networkAttachmentDefinitionName.
+	// +optional
+	NetworkAttachmentDefinitionName string `json:"networkAttachmentDefinitionName,omitempty"`
+}
+
+// PluginChain is a CNI plugin chain in which Linkerd CNI is placed.
+type PluginChain struct {
+	// Plugins are the CNI plugin configurations chained with Linkerd CNI, e.g. tuning, sbr or bandwidth.
+	// The "name" and "cniVersion" fields are inherited from the chain and can be omitted.
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Plugins []runtime.RawExtension `json:"plugins"`
+
+	// LinkerdPosition is the index of Linkerd CNI in the chain, 0 puts it before all the plugins.
+	// Defaults to the end of the chain.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	LinkerdPosition *int32 `json:"linkerdPosition,omitempty"`
+}
+
+// LinkerdMultusConfigStatus defines the observed state of LinkerdMultusConfig.
+type LinkerdMultusConfigStatus struct {
+	// ObservedGeneration is the most recent generation observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// CNIConfigSource is the Linkerd CNI configuration source the NetworkAttachmentDefinitions are rendered from.
+	// +optional
+	CNIConfigSource string `json:"cniConfigSource,omitempty"`
+
+	// ManagedNamespaces is the number of namespaces which require a NetworkAttachmentDefinition.
+	// +optional
+	ManagedNamespaces int32 `json:"managedNamespaces"`
+
+	// Drift is the result of the last periodic drift check.
+	// +optional
+	Drift *DriftReport `json:"drift,omitempty"`
+
+	// Conditions represent the latest available observations of the configuration state.
+	// +optional
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// DriftReport is the result of a periodic comparison of the desired and the actual
+// NetworkAttachmentDefinitions in all namespaces.
+type DriftReport struct {
+	// LastCheckTime is the time the drift check finished.
+	LastCheckTime metav1.Time `json:"lastCheckTime"`
+
+	// CheckedNamespaces is the number of checked namespaces.
+	CheckedNamespaces int32 `json:"checkedNamespaces"`
+
+	// Missing is the number of namespaces without the required NetworkAttachmentDefinition.
+	Missing int32 `json:"missing"`
+
+	// Outdated is the number of NetworkAttachmentDefinitions which differ from the Linkerd CNI configuration.
+	Outdated int32 `json:"outdated"`
+
+	// Stale is the number of managed NetworkAttachmentDefinitions which are not required.
+	Stale int32 `json:"stale"`
+
+	// Enqueued is the number of drifted namespaces enqueued for the reconciliation.
+	Enqueued int32 `json:"enqueued"`
+
+	// Repaired is the number of namespaces enqueued by the previous check which are no longer drifted.
+	Repaired int32 `json:"repaired"`
+
+	// DriftedNamespaces lists the drifted namespaces, the list is truncated to 50 items.
+	// +optional
+	DriftedNamespaces []string `json:"driftedNamespaces,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Mode",type=string,JSONPath=`.spec.mode`
+//+kubebuilder:printcolumn:name="CNI Source",type=string,JSONPath=`.status.cniConfigSource`
+//+kubebuilder:printcolumn:name="Namespaces",type=integer,JSONPath=`.status.managedNamespaces`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// LinkerdMultusConfig is the Schema for the linkerdmultusconfigs API.
+// The operator reads the LinkerdMultusConfig with the name given by
+// its -config-name flag and applies the changes without a restart.
+type LinkerdMultusConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   LinkerdMultusConfigSpec   `json:"spec,omitempty"`
+	Status LinkerdMultusConfigStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// LinkerdMultusConfigList contains a list of LinkerdMultusConfig.
+type LinkerdMultusConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []LinkerdMultusConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&LinkerdMultusConfig{}, &LinkerdMultusConfigList{})
+}
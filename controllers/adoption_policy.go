@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+)
+
+// AdoptionPolicy defines what the controller does with a pre-existing
+// NetworkAttachmentDefinition which is not labelled as managed by the operator.
+type AdoptionPolicy string
+
+const (
+	// AdoptionPolicyAdopt - the controller labels the NetworkAttachmentDefinition
+	// and manages it as if it had created it.
+	AdoptionPolicyAdopt AdoptionPolicy = "adopt"
+	// AdoptionPolicyIgnore - the controller leaves the NetworkAttachmentDefinition untouched.
+	AdoptionPolicyIgnore AdoptionPolicy = "ignore"
+	// AdoptionPolicyFail - the controller leaves the NetworkAttachmentDefinition
+	// untouched and reports a reconciliation error.
+	AdoptionPolicyFail AdoptionPolicy = "fail"
+)
+
+// ErrNetAttachNotManaged is returned when a NetworkAttachmentDefinition is required in a namespace
+// but the existing one is not managed by the operator and AdoptionPolicyFail is set.
+var ErrNetAttachNotManaged = errors.New("Multus NetworkAttachmentDefinition is not managed by the operator")
+
+// ParseAdoptionPolicy converts a string to AdoptionPolicy and checks that the value is known.
+func ParseAdoptionPolicy(value string) (AdoptionPolicy, error) {
+	switch policy := AdoptionPolicy(value); policy {
+	case AdoptionPolicyAdopt, AdoptionPolicyIgnore, AdoptionPolicyFail:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown NetworkAttachmentDefinition adoption policy %q, expected one of: %s, %s, %s",
+			value, AdoptionPolicyAdopt, AdoptionPolicyIgnore, AdoptionPolicyFail)
+	}
+}
@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	netattachv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// applyRecordingClient records the server-side apply patches, which the fake client does not support,
+// instead of sending them.
+type applyRecordingClient struct {
+	client.Client
+	// applied are the applied NetworkAttachmentDefinitions.
+	applied []*netattachv1.NetworkAttachmentDefinition
+	// forced are the ForceOwnership options of the applied patches.
+	forced []bool
+}
+
+// Patch implements client.Writer.
+func (c *applyRecordingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+
+	patchOpts := (&client.PatchOptions{}).ApplyOptions(opts)
+
+	c.applied = append(c.applied, obj.(*netattachv1.NetworkAttachmentDefinition).DeepCopy())
+	c.forced = append(c.forced, patchOpts.Force != nil && *patchOpts.Force)
+
+	return nil
+}
+
+func TestParseAdoptionPolicy(t *testing.T) {
+	tests := []struct {
+		value       string
+		expected    AdoptionPolicy
+		expectedErr bool
+	}{
+		{"adopt", AdoptionPolicyAdopt, false},
+		{"ignore", AdoptionPolicyIgnore, false},
+		{"fail", AdoptionPolicyFail, false},
+		{"Adopt", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseAdoptionPolicy(tt.value)
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("ParseAdoptionPolicy() error = %v, want error %v", err, tt.expectedErr)
+			}
+
+			if got != tt.expected {
+				t.Errorf("ParseAdoptionPolicy() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReconcileAdoptionPolicy(t *testing.T) {
+	unlabelled := newTestNetAttach(testLinkerdCNIConfig)
+	unlabelled.Labels = nil
+
+	foreign := newTestNetAttach(testLinkerdCNIConfig)
+	foreign.Labels = managedLabels("other")
+
+	tests := []struct {
+		name           string
+		netAttach      *netattachv1.NetworkAttachmentDefinition
+		policy         AdoptionPolicy
+		expectedApply  bool
+		expectedErr    error
+		expectedResult string
+		expectedEvent  string
+	}{
+		{"unlabelled is adopted", unlabelled, AdoptionPolicyAdopt, true, nil, ResultUpdated, ResultAdopted},
+		{"unlabelled is ignored", unlabelled, AdoptionPolicyIgnore, false, nil, ResultIgnored, ""},
+		{"unlabelled fails", unlabelled, AdoptionPolicyFail, false, ErrNetAttachNotManaged, ResultNotManaged,
+			ResultNotManaged},
+		{"other instance's is not adopted", foreign, AdoptionPolicyAdopt, false, nil, ResultIgnored, ""},
+		{"other instance's is ignored", foreign, AdoptionPolicyIgnore, false, nil, ResultIgnored, ""},
+		{"other instance's does not fail", foreign, AdoptionPolicyFail, false, nil, ResultIgnored, ""},
+		{"managed is updated on adopt", newTestNetAttach(testLinkerdCNIConfig), AdoptionPolicyAdopt, true, nil,
+			ResultUpdated, ResultUpdated},
+		{"managed is updated on ignore", newTestNetAttach(testLinkerdCNIConfig), AdoptionPolicyIgnore, true, nil,
+			ResultUpdated, ResultUpdated},
+		{"managed is updated on fail", newTestNetAttach(testLinkerdCNIConfig), AdoptionPolicyFail, true, nil,
+			ResultUpdated, ResultUpdated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			ns := newDriftTestNamespace(true)
+
+			r := newTestNamespaceReconciler(t, ns, tt.netAttach.DeepCopy())
+			c := &applyRecordingClient{Client: r.Client}
+			r.Client = c
+			r.AdoptionPolicy = tt.policy
+
+			_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: ns.Name}})
+			if !errors.Is(err, tt.expectedErr) || (err != nil) != (tt.expectedErr != nil) {
+				t.Fatalf("Reconcile() error = %v, want %v", err, tt.expectedErr)
+			}
+
+			if isApplied := len(c.applied) != 0; isApplied != tt.expectedApply {
+				t.Fatalf("NetworkAttachmentDefinition applied = %v, want %v", isApplied, tt.expectedApply)
+			}
+
+			if tt.expectedApply && !isManagedNetAttach(c.applied[0], testOperatorInstance) {
+				t.Errorf("applied NetworkAttachmentDefinition labels = %v, want managed", c.applied[0].Labels)
+			}
+
+			if err := c.Get(ctx, client.ObjectKeyFromObject(ns), ns); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+
+			if status := getNamespaceStatus(ns); status == nil || status.Result != tt.expectedResult {
+				t.Errorf("Namespace status = %+v, want result %s", status, tt.expectedResult)
+			}
+
+			events := drainEvents(r.Recorder.(*record.FakeRecorder))
+
+			switch {
+			case tt.expectedEvent == "" && len(events) != 0:
+				t.Errorf("Events = %q, want none", events)
+			case tt.expectedEvent != "" && !containsEventReason(events, tt.expectedEvent):
+				t.Errorf("Events = %q, want %s", events, tt.expectedEvent)
+			}
+		})
+	}
+}
+
+// containsEventReason checks if any of the Events recorded by the fake recorder has the reason.
+func containsEventReason(events []string, reason string) bool {
+	for _, e := range events {
+		if strings.HasPrefix(e, corev1.EventTypeNormal+" "+reason+" ") ||
+			strings.HasPrefix(e, corev1.EventTypeWarning+" "+reason+" ") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/containernetworking/cni/pkg/version"
+
+	"github.com/ErmakovDmitriy/linkerd-multus-attach-operator/settings"
+)
+
+// ErrInvalidCNIChain is returned when the configured CNI plugin chain can not be rendered.
+var ErrInvalidCNIChain = errors.New("invalid CNI plugin chain")
+
+// minChainCNIVersion is the first CNI specification version which supports plugin chains (conflist).
+const minChainCNIVersion = "0.3.0"
+
+// renderNetAttachConfig returns the NetworkAttachmentDefinition configuration with the negotiated
+// CNI specification version: the Linkerd CNI configuration or, if the chain is set,
+// the conflist of Linkerd CNI and the chain plugins. isDowngraded is true, if the Linkerd CNI
+// configuration version is not supported and the configuration is rendered with an older one.
+func renderNetAttachConfig(linkerdConfig *CNIPluginConf, cfg settings.Settings) (string, bool, error) {
+	cniVersion, isDowngraded, err := negotiateCNIVersion(linkerdConfig.CNIVersion, cfg)
+	if err != nil {
+		return "", false, err
+	}
+
+	config := *linkerdConfig
+	config.CNIVersion = cniVersion
+
+	chain := cfg.Chain
+	if chain == nil || len(chain.Plugins) == 0 {
+		raw, err := json.Marshal(&config)
+		if err != nil {
+			return "", false, fmt.Errorf("NetworkAttachmentDefinition configuration JSON Marshal error: %w", err)
+		}
+
+		return string(raw), isDowngraded, nil
+	}
+
+	isSupported, err := version.GreaterThanOrEqualTo(config.CNIVersion, minChainCNIVersion)
+	if err != nil {
+		return "", false, fmt.Errorf("%w: Linkerd CNI cniVersion %q: %s", ErrInvalidCNIChain, config.CNIVersion, err.Error())
+	}
+
+	if !isSupported {
+		return "", false, fmt.Errorf("%w: Linkerd CNI cniVersion %q does not support plugin chains, %s or newer is required",
+			ErrInvalidCNIChain, config.CNIVersion, minChainCNIVersion)
+	}
+
+	if chain.LinkerdPosition > len(chain.Plugins) {
+		return "", false, fmt.Errorf("%w: Linkerd CNI position %d is out of the chain of %d plugins",
+			ErrInvalidCNIChain, chain.LinkerdPosition, len(chain.Plugins))
+	}
+
+	// The chain's name and cniVersion apply to all the plugins.
+	linkerdPlugin, err := toJSONFields(&config)
+	if err != nil {
+		return "", false, err
+	}
+
+	delete(linkerdPlugin, "name")
+	delete(linkerdPlugin, "cniVersion")
+
+	var plugins = make([]interface{}, 0, len(chain.Plugins)+1)
+
+	for i, raw := range chain.Plugins {
+		plugin, err := parseChainPlugin(raw, config.CNIVersion)
+		if err != nil {
+			return "", false, fmt.Errorf("%w: plugin %d: %s", ErrInvalidCNIChain, i, err.Error())
+		}
+
+		plugins = append(plugins, plugin)
+	}
+
+	position := chain.LinkerdPosition
+	if position < 0 {
+		position = len(plugins)
+	}
+
+	plugins = append(plugins[:position], append([]interface{}{linkerdPlugin}, plugins[position:]...)...)
+
+	raw, err := json.Marshal(map[string]interface{}{
+		"cniVersion": config.CNIVersion,
+		"name":       config.Name,
+		"plugins":    plugins,
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("NetworkAttachmentDefinition configuration JSON Marshal error: %w", err)
+	}
+
+	return string(raw), isDowngraded, nil
+}
+
+// parseChainPlugin parses a chain plugin configuration and checks that it has a type and,
+// if the plugin sets cniVersion, the version is the chain's one.
+func parseChainPlugin(raw json.RawMessage, cniVersion string) (map[string]interface{}, error) {
+	var plugin map[string]interface{}
+
+	if err := json.Unmarshal(raw, &plugin); err != nil {
+		return nil, fmt.Errorf("can not parse the plugin configuration: %w", err)
+	}
+
+	if pluginType, _ := plugin["type"].(string); pluginType == "" {
+		return nil, errors.New("plugin type is not set")
+	}
+
+	if pluginVersion, ok := plugin["cniVersion"]; ok {
+		if pluginVersion != cniVersion {
+			return nil, fmt.Errorf("plugin cniVersion %v is incompatible with Linkerd CNI cniVersion %q",
+				formatJSONValue(pluginVersion), cniVersion)
+		}
+
+		// The chain sets the version.
+		delete(plugin, "cniVersion")
+	}
+
+	return plugin, nil
+}
@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	k8s "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
+	"github.com/ErmakovDmitriy/linkerd-multus-attach-operator/settings"
+)
+
+// newTestChain returns a chain of the bandwidth and sbr plugins.
+func newTestChain(linkerdPosition int) *settings.Chain {
+	return &settings.Chain{
+		Plugins: []json.RawMessage{
+			json.RawMessage(`{"type":"bandwidth","ingressRate":1000}`),
+			json.RawMessage(`{"type":"sbr","cniVersion":"0.4.0"}`),
+		},
+		LinkerdPosition: linkerdPosition,
+	}
+}
+
+func TestRenderNetAttachConfig(t *testing.T) {
+	t.Run("no chain", func(t *testing.T) {
+		linkerdConfig := newTestCNIPluginConf()
+		linkerdConfig.CNIVersion = "0.4.0"
+
+		raw, isDowngraded, err := renderNetAttachConfig(linkerdConfig, settings.Settings{})
+		if err != nil {
+			t.Fatalf("renderNetAttachConfig() error = %v", err)
+		}
+
+		if isDowngraded {
+			t.Error("renderNetAttachConfig() isDowngraded = true, want false")
+		}
+
+		var got = &CNIPluginConf{}
+		if err := json.Unmarshal([]byte(raw), got); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+
+		if !reflect.DeepEqual(got, linkerdConfig) {
+			t.Errorf("renderNetAttachConfig() = %s, want %+v", raw, linkerdConfig)
+		}
+	})
+
+	tests := []struct {
+		name            string
+		linkerdPosition int
+		expected        []string
+	}{
+		{"end of the chain", -1, []string{"bandwidth", "sbr", k8s.MultusCNIType}},
+		{"start of the chain", 0, []string{k8s.MultusCNIType, "bandwidth", "sbr"}},
+		{"middle of the chain", 1, []string{"bandwidth", k8s.MultusCNIType, "sbr"}},
+		{"position after the last plugin", 2, []string{"bandwidth", "sbr", k8s.MultusCNIType}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			linkerdConfig := newTestCNIPluginConf()
+			linkerdConfig.CNIVersion = "0.4.0"
+
+			raw, _, err := renderNetAttachConfig(linkerdConfig, settings.Settings{Chain: newTestChain(tt.linkerdPosition)})
+			if err != nil {
+				t.Fatalf("renderNetAttachConfig() error = %v", err)
+			}
+
+			var got struct {
+				CNIVersion string                   `json:"cniVersion"`
+				Name       string                   `json:"name"`
+				Plugins    []map[string]interface{} `json:"plugins"`
+			}
+
+			if err := json.Unmarshal([]byte(raw), &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			if got.CNIVersion != "0.4.0" || got.Name != linkerdConfig.Name {
+				t.Errorf("chain cniVersion, name = %q, %q, want %q, %q", got.CNIVersion, got.Name, "0.4.0", linkerdConfig.Name)
+			}
+
+			var types = make([]string, 0, len(got.Plugins))
+
+			for _, plugin := range got.Plugins {
+				types = append(types, plugin["type"].(string))
+
+				if _, ok := plugin["cniVersion"]; ok {
+					t.Errorf("plugin %v has cniVersion, want the chain's one", plugin["type"])
+				}
+
+				if _, ok := plugin["name"]; ok {
+					t.Errorf("plugin %v has name, want the chain's one", plugin["type"])
+				}
+			}
+
+			if !reflect.DeepEqual(types, tt.expected) {
+				t.Errorf("chain plugins = %q, want %q", types, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRenderNetAttachConfigDowngrade(t *testing.T) {
+	linkerdConfig := newTestCNIPluginConf()
+	linkerdConfig.CNIVersion = "1.0.0"
+
+	raw, isDowngraded, err := renderNetAttachConfig(linkerdConfig, settings.Settings{Chain: newTestChain(-1)})
+	if err != nil {
+		t.Fatalf("renderNetAttachConfig() error = %v", err)
+	}
+
+	if !isDowngraded {
+		t.Error("renderNetAttachConfig() isDowngraded = false, want true")
+	}
+
+	var got struct {
+		CNIVersion string `json:"cniVersion"`
+	}
+
+	if err := json.Unmarshal([]byte(raw), &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if got.CNIVersion != "0.4.0" {
+		t.Errorf("chain cniVersion = %q, want %q", got.CNIVersion, "0.4.0")
+	}
+}
+
+func TestRenderNetAttachConfigInvalidChain(t *testing.T) {
+	tests := []struct {
+		name       string
+		cniVersion string
+		chain      *settings.Chain
+	}{
+		{"Linkerd CNI position is out of the chain", "0.4.0", newTestChain(3)},
+		{"CNI version does not support chains", "0.2.0", newTestChain(-1)},
+		{"malformed plugin", "0.4.0", &settings.Chain{Plugins: []json.RawMessage{json.RawMessage(`{`)}, LinkerdPosition: -1}},
+		{"plugin without type", "0.4.0",
+			&settings.Chain{Plugins: []json.RawMessage{json.RawMessage(`{"mtu":1400}`)}, LinkerdPosition: -1}},
+		{"plugin with other cniVersion", "0.4.0",
+			&settings.Chain{Plugins: []json.RawMessage{json.RawMessage(`{"type":"sbr","cniVersion":"0.3.1"}`)}, LinkerdPosition: -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			linkerdConfig := newTestCNIPluginConf()
+			linkerdConfig.CNIVersion = tt.cniVersion
+
+			if _, _, err := renderNetAttachConfig(linkerdConfig, settings.Settings{Chain: tt.chain}); !errors.Is(err, ErrInvalidCNIChain) {
+				t.Errorf("renderNetAttachConfig() error = %v, want %v", err, ErrInvalidCNIChain)
+			}
+		})
+	}
+}
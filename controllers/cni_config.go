@@ -2,20 +2,27 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 
 	k8s "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
 	"github.com/containernetworking/cni/pkg/types"
-	corev1 "k8s.io/api/core/v1"
-	apitypes "k8s.io/apimachinery/pkg/types"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// ErrCNIConfigMapKeyNotFound is an error which is returned when the controller
-// can not find Linkerd CNI config in the Linkerd CNI ConfigMap.
-var ErrCNIConfigMapKeyNotFound = errors.New("Linkerd CNI config is key " + k8s.LinkerdCNIConfigMapKey + " is not in ConfigMap")
+// ErrCNIConfigKeyNotFound is an error which is returned when the controller
+// can not find Linkerd CNI config in the Linkerd CNI ConfigMap or Secret.
+var ErrCNIConfigKeyNotFound = errors.New("Linkerd CNI config key is not found")
+
+// ErrCNIConfigUnmarshal is an error which is returned when the Linkerd CNI config
+// in the Linkerd CNI ConfigMap is not a valid JSON.
+var ErrCNIConfigUnmarshal = errors.New("can not JSON Unmarshal CNI Config")
+
+// ErrCNIConfigNotFound is an error which is returned when the Linkerd CNI
+// ConfigMap, Secret or file does not exist (yet).
+var ErrCNIConfigNotFound = errors.New("Linkerd CNI configuration is not found")
 
 // ProxyInit is the configuration for the proxy-init binary.
 type ProxyInit struct {
@@ -46,39 +53,60 @@ type CNIPluginConf struct {
 func newCNIPluginConf() *CNIPluginConf {
 	return &CNIPluginConf{
 		NetConf: types.NetConf{
-			CNIVersion: k8s.MultusCNIVersion,
-			Name:       k8s.MultusNetworkAttachmentDefinitionName,
-			Type:       k8s.MultusCNIType,
+			Name: k8s.MultusNetworkAttachmentDefinitionName,
+			Type: k8s.MultusCNIType,
 		},
 	}
 }
 
 // loadCNINetworkConfig loads CNI Configuration from given raw string.
-func loadCNINetworkConfig(cm *corev1.ConfigMap, cniKubeconfigPath string) (*CNIPluginConf, error) {
-	cniConfigRAW, ok := cm.Data[k8s.LinkerdCNIConfigMapKey]
-	if !ok {
-		return nil, fmt.Errorf("%w %s/%s", ErrCNIConfigMapKeyNotFound, cm.Namespace, cm.Name)
-	}
-
+func loadCNINetworkConfig(cniConfigRAW string, cniKubeconfigPath string) (*CNIPluginConf, error) {
 	var pc = newCNIPluginConf()
 
 	if err := json.Unmarshal([]byte(cniConfigRAW), pc); err != nil {
-		return nil, fmt.Errorf("can not JSON Unmarshal CNI Config: %w", err)
+		cniConfigLoadErrorsTotal.WithLabelValues(cniConfigErrorUnmarshal).Inc()
+
+		return nil, fmt.Errorf("%w: %s", ErrCNIConfigUnmarshal, err.Error())
+	}
+
+	// The CNI version is negotiated when the NetworkAttachmentDefinitions are rendered,
+	// the configuration without the version is treated as the oldest version Linkerd CNI implements.
+	if pc.CNIVersion == "" {
+		pc.CNIVersion = k8s.MultusCNIVersion
 	}
 
 	// Patch Kubeconfig path as it is not set in the Linkerd CNI ConfigMap (placeholder).
 	pc.Kubernetes.Kubeconfig = cniKubeconfigPath
 
+	// Invalid configuration is not propagated, the existing NetworkAttachmentDefinitions are kept.
+	if err := validateCNIPluginConf(pc); err != nil {
+		cniConfigLoadErrorsTotal.WithLabelValues(cniConfigErrorInvalid).Inc()
+
+		return nil, err
+	}
+
 	return pc, nil
 }
 
-func getCNINetworkConfig(ctx context.Context, client client.Client, linkerdCNINamespace, cniKubeconfigPath string) (*CNIPluginConf, error) {
-	var cm = &corev1.ConfigMap{}
-
-	if err := client.Get(ctx, apitypes.NamespacedName{Namespace: linkerdCNINamespace, Name: k8s.LinkerdCNIConfigMapName}, cm); err != nil {
-		return nil, fmt.Errorf("can not get Linkerd-CNI ConfigMap %s/%s: %w",
-			linkerdCNINamespace, k8s.LinkerdCNIConfigMapName, err)
+func getCNINetworkConfig(ctx context.Context, source CNIConfigSource, linkerdCNINamespace, cniKubeconfigPath string) (*CNIPluginConf, error) {
+	cniConfigRAW, err := source.Get(ctx, linkerdCNINamespace)
+	if err != nil {
+		return nil, err
 	}
 
-	return loadCNINetworkConfig(cm, cniKubeconfigPath)
+	return loadCNINetworkConfig(cniConfigRAW, cniKubeconfigPath)
+}
+
+// isCNIConfigInvalid checks if an error is caused by an invalid Linkerd CNI configuration source content.
+func isCNIConfigInvalid(err error) bool {
+	return errors.Is(err, ErrCNIConfigKeyNotFound) || errors.Is(err, ErrCNIConfigUnmarshal) ||
+		errors.Is(err, ErrCNIConfigInvalid)
+}
+
+// cniConfigHash returns a hash of the NetworkAttachmentDefinition configuration which identifies
+// the configuration a NetworkAttachmentDefinition was rendered from.
+func cniConfigHash(config string) string {
+	hash := sha256.Sum256([]byte(config))
+
+	return hex.EncodeToString(hash[:])
 }
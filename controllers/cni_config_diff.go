@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// noValue is shown in a difference for a field which is not set.
+const noValue = "<none>"
+
+// parseNetAttachConfig parses a NetworkAttachmentDefinition configuration to its generic JSON representation.
+// A Linkerd CNI configuration is parsed as CNIPluginConf, so its unknown fields do not matter,
+// a plugin chain (conflist) is compared as a whole.
+func parseNetAttachConfig(raw string) (map[string]interface{}, error) {
+	var fields map[string]interface{}
+
+	if err := json.Unmarshal([]byte(raw), &fields); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCNIConfigUnmarshal, err.Error())
+	}
+
+	if _, isChain := fields["plugins"]; isChain {
+		return fields, nil
+	}
+
+	var config = &CNIPluginConf{}
+
+	if err := json.Unmarshal([]byte(raw), config); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCNIConfigUnmarshal, err.Error())
+	}
+
+	return toJSONFields(config)
+}
+
+// diffNetAttachConfig compares two NetworkAttachmentDefinition configurations semantically: key order,
+// whitespace and unknown Linkerd CNI fields do not matter. Returns the differences as
+// "{{ JSON path }}: {{ current }} -> {{ required }}" sorted by the path, e.g.
+// "linkerd.incoming-proxy-port: 4143 -> 4144" or "plugins[1].type: \"sbr\" -> \"tuning\"".
+func diffNetAttachConfig(current, required map[string]interface{}) []string {
+	var diff []string
+
+	diffJSONValues("", current, required, &diff)
+
+	return diff
+}
+
+// toJSONFields converts a CNI configuration to its generic JSON representation.
+func toJSONFields(config *CNIPluginConf) (map[string]interface{}, error) {
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("CNI configuration JSON Marshal error: %w", err)
+	}
+
+	var fields map[string]interface{}
+
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, fmt.Errorf("CNI configuration JSON Unmarshal error: %w", err)
+	}
+
+	return fields, nil
+}
+
+// diffJSONValues appends the differences between two generic JSON values to diff.
+// Objects are compared field by field, arrays of the same length element by element,
+// other values are compared as a whole.
+func diffJSONValues(path string, current, required interface{}, diff *[]string) {
+	currentArray, isCurrentArray := current.([]interface{})
+	requiredArray, isRequiredArray := required.([]interface{})
+
+	if isCurrentArray && isRequiredArray && len(currentArray) == len(requiredArray) {
+		for i := range currentArray {
+			diffJSONValues(fmt.Sprintf("%s[%d]", path, i), currentArray[i], requiredArray[i], diff)
+		}
+
+		return
+	}
+
+	currentObject, isCurrentObject := current.(map[string]interface{})
+	requiredObject, isRequiredObject := required.(map[string]interface{})
+
+	if !isCurrentObject || !isRequiredObject {
+		if !reflect.DeepEqual(current, required) {
+			*diff = append(*diff, fmt.Sprintf("%s: %s -> %s", path, formatJSONValue(current), formatJSONValue(required)))
+		}
+
+		return
+	}
+
+	keys := make([]string, 0, len(currentObject)+len(requiredObject))
+
+	for key := range currentObject {
+		keys = append(keys, key)
+	}
+
+	for key := range requiredObject {
+		if _, ok := currentObject[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fieldPath := key
+		if path != "" {
+			fieldPath = path + "." + key
+		}
+
+		diffJSONValues(fieldPath, currentObject[key], requiredObject[key], diff)
+	}
+}
+
+// formatJSONValue formats a generic JSON value for a difference.
+func formatJSONValue(value interface{}) string {
+	if value == nil {
+		return noValue
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+
+	return string(raw)
+}
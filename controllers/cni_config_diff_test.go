@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	netattachv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8s "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
+)
+
+const (
+	testOperatorInstance = "default"
+
+	testLinkerdCNIConfig = `{"cniVersion":"0.3.1","name":"linkerd-cni","type":"linkerd-cni",` +
+		`"linkerd":{"incoming-proxy-port":4143,"outgoing-proxy-port":4140}}`
+)
+
+// newTestNetAttach returns a NetworkAttachmentDefinition managed by the test operator instance.
+func newTestNetAttach(config string, finalizers ...string) *netattachv1.NetworkAttachmentDefinition {
+	return &netattachv1.NetworkAttachmentDefinition{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       k8s.MultusNetworkAttachmentDefinitionName,
+			Namespace:  "app",
+			Labels:     managedLabels(testOperatorInstance),
+			Finalizers: finalizers,
+		},
+		Spec: netattachv1.NetworkAttachmentDefinitionSpec{Config: config},
+	}
+}
+
+func TestNetAttachDiff(t *testing.T) {
+	tests := []struct {
+		name            string
+		current         *netattachv1.NetworkAttachmentDefinition
+		inUseProtection bool
+		config          string
+		expected        []string
+	}{
+		{
+			name:    "equal",
+			current: newTestNetAttach(testLinkerdCNIConfig),
+			config:  testLinkerdCNIConfig,
+		},
+		{
+			name: "key order, whitespace and unknown fields do not matter",
+			current: newTestNetAttach(`{ "linkerd": { "outgoing-proxy-port": 4140, "incoming-proxy-port": 4143 },
+				"type": "linkerd-cni", "name": "linkerd-cni", "cniVersion": "0.3.1", "unknown": true }`),
+			config: testLinkerdCNIConfig,
+		},
+		{
+			name: "changed field",
+			current: newTestNetAttach(`{"cniVersion":"0.3.1","name":"linkerd-cni","type":"linkerd-cni",` +
+				`"linkerd":{"incoming-proxy-port":4144,"outgoing-proxy-port":4140}}`),
+			config:   testLinkerdCNIConfig,
+			expected: []string{"linkerd.incoming-proxy-port: 4144 -> 4143"},
+		},
+		{
+			name:    "missing field",
+			current: newTestNetAttach(`{"cniVersion":"0.3.1","name":"linkerd-cni","type":"linkerd-cni"}`),
+			config:  testLinkerdCNIConfig,
+			expected: []string{
+				"linkerd.incoming-proxy-port: <none> -> 4143",
+				"linkerd.outgoing-proxy-port: <none> -> 4140",
+			},
+		},
+		{
+			name:     "changed chain plugin",
+			current:  newTestNetAttach(`{"cniVersion":"0.3.1","plugins":[{"type":"linkerd-cni"},{"type":"sbr"}]}`),
+			config:   `{"cniVersion":"0.3.1","plugins":[{"type":"linkerd-cni"},{"type":"tuning"}]}`,
+			expected: []string{`plugins[1].type: "sbr" -> "tuning"`},
+		},
+		{
+			name:     "added chain plugin",
+			current:  newTestNetAttach(`{"cniVersion":"0.3.1","plugins":[{"type":"linkerd-cni"}]}`),
+			config:   `{"cniVersion":"0.3.1","plugins":[{"type":"linkerd-cni"},{"type":"sbr"}]}`,
+			expected: []string{`plugins: [{"type":"linkerd-cni"}] -> [{"type":"linkerd-cni"},{"type":"sbr"}]`},
+		},
+		{
+			name: "not managed",
+			current: func() *netattachv1.NetworkAttachmentDefinition {
+				netAttach := newTestNetAttach(testLinkerdCNIConfig)
+				netAttach.Labels = nil
+
+				return netAttach
+			}(),
+			config:   testLinkerdCNIConfig,
+			expected: []string{"labels: not managed by operator instance " + testOperatorInstance},
+		},
+		{
+			name:            "missing finalizer",
+			current:         newTestNetAttach(testLinkerdCNIConfig),
+			inUseProtection: true,
+			config:          testLinkerdCNIConfig,
+			expected:        []string{"finalizers: " + k8s.NetAttachFinalizer + " is missing"},
+		},
+		{
+			name:            "finalizer with in-use protection",
+			current:         newTestNetAttach(testLinkerdCNIConfig, k8s.NetAttachFinalizer),
+			inUseProtection: true,
+			config:          testLinkerdCNIConfig,
+		},
+		{
+			name:     "finalizer without in-use protection",
+			current:  newTestNetAttach(testLinkerdCNIConfig, k8s.NetAttachFinalizer),
+			config:   testLinkerdCNIConfig,
+			expected: []string{"finalizers: " + k8s.NetAttachFinalizer + " is not required"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := netAttachDiff(tt.current, testOperatorInstance, tt.inUseProtection, tt.config)
+			if err != nil {
+				t.Fatalf("netAttachDiff() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("netAttachDiff() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNetAttachDiffMalformedConfig(t *testing.T) {
+	t.Run("malformed current config is a difference", func(t *testing.T) {
+		got, err := netAttachDiff(newTestNetAttach("{"), testOperatorInstance, false, testLinkerdCNIConfig)
+		if err != nil {
+			t.Fatalf("netAttachDiff() error = %v", err)
+		}
+
+		if len(got) != 1 || !strings.HasPrefix(got[0], "config: "+ErrCNIConfigUnmarshal.Error()) {
+			t.Errorf("netAttachDiff() = %q, want the config parse error", got)
+		}
+	})
+
+	t.Run("malformed required config is an error", func(t *testing.T) {
+		_, err := netAttachDiff(newTestNetAttach(testLinkerdCNIConfig), testOperatorInstance, false, "{")
+		if !errors.Is(err, ErrCNIConfigUnmarshal) {
+			t.Errorf("netAttachDiff() error = %v, want %v", err, ErrCNIConfigUnmarshal)
+		}
+	})
+}
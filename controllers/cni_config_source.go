@@ -0,0 +1,346 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// Kinds of the Linkerd CNI configuration sources.
+const (
+	// CNIConfigSourceConfigMap - a key of a ConfigMap in the Linkerd CNI namespace of a mesh.
+	CNIConfigSourceConfigMap = "configmap"
+	// CNIConfigSourceSecret - a key of a Secret in the Linkerd CNI namespace of a mesh.
+	CNIConfigSourceSecret = "secret"
+	// CNIConfigSourceFile - a local file, e.g. a mounted ConfigMap or Secret, used by all the meshes.
+	CNIConfigSourceFile = "file"
+)
+
+// ErrCNIConfigNamespaceNotCached is returned when the Linkerd CNI configuration of a mesh is in a namespace
+// the source does not read the objects in, e.g. the Secret of a mesh out of the Linkerd CNI namespaces
+// or the ConfigMap out of the namespace scope.
+var ErrCNIConfigNamespaceNotCached = errors.New("Linkerd CNI namespace is not cached by the operator")
+
+// fileSourcePollInterval is the default period of the Linkerd CNI configuration file change checks.
+// A mounted ConfigMap or Secret is replaced by a symlink swap, so the content is polled instead of watched.
+const fileSourcePollInterval = 10 * time.Second
+
+// CNIConfigSource provides the raw Linkerd CNI configuration of the meshes.
+type CNIConfigSource interface {
+	// Get returns the Linkerd CNI configuration JSON of the mesh which Linkerd CNI is installed in cniNamespace.
+	// Returns ErrCNIConfigNotFound or ErrCNIConfigKeyNotFound if there is no configuration.
+	Get(ctx context.Context, cniNamespace string) (string, error)
+	// Describe returns the human readable source of the mesh's configuration.
+	Describe(cniNamespace string) string
+	// Watch makes the controller enqueue the requests returned by mapFunc when the configuration changes.
+	// mapFunc gets the Linkerd CNI namespace of the changed configuration, empty one means all the meshes.
+	Watch(blder *builder.Builder, mapFunc func(cniNamespace string) []reconcile.Request) *builder.Builder
+}
+
+// NewCNIConfigSource returns the Linkerd CNI configuration source of the given kind.
+// name and key are used by the ConfigMap and Secret sources, path by the file source.
+// The Secret source reads the Secret only in the cniNamespaces, the ConfigMap source only in the cachedNamespaces
+// the manager's cache reads the namespaced objects in, nil means all the namespaces.
+func NewCNIConfigSource(mgr manager.Manager, kind, name, key, path string,
+	cniNamespaces, cachedNamespaces []string) (CNIConfigSource, error) {
+	switch kind {
+	case CNIConfigSourceConfigMap:
+		return &ConfigMapCNIConfigSource{Client: mgr.GetClient(), Name: name, Key: key, Namespaces: cachedNamespaces}, nil
+	case CNIConfigSourceSecret:
+		return NewSecretCNIConfigSource(mgr.GetConfig(),
+			cache.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper()}, cniNamespaces, name, key)
+	case CNIConfigSourceFile:
+		if path == "" {
+			return nil, errors.New("Linkerd CNI configuration file path is not set")
+		}
+
+		return &FileCNIConfigSource{Path: path, PollInterval: fileSourcePollInterval}, nil
+	default:
+		return nil, fmt.Errorf("unknown Linkerd CNI configuration source %q, expected one of: %s, %s, %s",
+			kind, CNIConfigSourceConfigMap, CNIConfigSourceSecret, CNIConfigSourceFile)
+	}
+}
+
+// ConfigMapCNIConfigSource reads the Linkerd CNI configuration from a ConfigMap key.
+type ConfigMapCNIConfigSource struct {
+	Client client.Reader
+	// Name is the name of the ConfigMap.
+	Name string
+	// Key is the ConfigMap key which contains the configuration.
+	Key string
+	// Namespaces are the namespaces the Client reads the ConfigMaps in, nil means all the namespaces.
+	Namespaces []string
+}
+
+// Get implements CNIConfigSource.
+func (s *ConfigMapCNIConfigSource) Get(ctx context.Context, cniNamespace string) (string, error) {
+	var cm = &corev1.ConfigMap{}
+
+	if s.Namespaces != nil && !containsString(s.Namespaces, cniNamespace) {
+		return "", fmt.Errorf("%w: ConfigMap %s/%s, the ConfigMaps are read only in: %s", ErrCNIConfigNamespaceNotCached,
+			cniNamespace, s.Name, strings.Join(s.Namespaces, ", "))
+	}
+
+	if err := s.Client.Get(ctx, types.NamespacedName{Namespace: cniNamespace, Name: s.Name}, cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			cniConfigLoadErrorsTotal.WithLabelValues(cniConfigErrorConfigMapNotFound).Inc()
+
+			return "", fmt.Errorf("%w: ConfigMap %s/%s", ErrCNIConfigNotFound, cniNamespace, s.Name)
+		}
+
+		return "", fmt.Errorf("can not get Linkerd-CNI ConfigMap %s/%s: %w", cniNamespace, s.Name, err)
+	}
+
+	raw, ok := cm.Data[s.Key]
+	if !ok {
+		cniConfigLoadErrorsTotal.WithLabelValues(cniConfigErrorKeyNotFound).Inc()
+
+		return "", fmt.Errorf("%w: key %s of ConfigMap %s/%s", ErrCNIConfigKeyNotFound, s.Key, cniNamespace, s.Name)
+	}
+
+	return raw, nil
+}
+
+// Describe implements CNIConfigSource.
+func (s *ConfigMapCNIConfigSource) Describe(cniNamespace string) string {
+	return fmt.Sprintf("ConfigMap %s/%s key %s", cniNamespace, s.Name, s.Key)
+}
+
+// Watch implements CNIConfigSource.
+func (s *ConfigMapCNIConfigSource) Watch(blder *builder.Builder,
+	mapFunc func(cniNamespace string) []reconcile.Request) *builder.Builder {
+	return blder.Watches(
+		&source.Kind{Type: &corev1.ConfigMap{}},
+		handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
+			return mapFunc(o.GetNamespace())
+		}),
+		builder.WithPredicates(predicate.Or(getObjectNameEventFilter(s.Name), getLinkerdCNIResourceEventFilter())),
+	)
+}
+
+// SecretCNIConfigSource reads the Linkerd CNI configuration from a Secret key.
+// The Secrets are cached by its own cache, which must be added to the manager,
+// only in the Linkerd CNI namespaces and only with the Secret's name,
+// so the operator needs neither the cluster-wide access to the Secrets nor caches all of them.
+type SecretCNIConfigSource struct {
+	// Cache caches the Secrets with the Name in the Namespaces.
+	Cache cache.Cache
+	// Name is the name of the Secret.
+	Name string
+	// Key is the Secret key which contains the configuration.
+	Key string
+	// Namespaces are the Linkerd CNI namespaces the Secret is read in.
+	Namespaces []string
+}
+
+// NewSecretCNIConfigSource returns the Secret source with the cache of the Secrets with the name in the namespaces.
+func NewSecretCNIConfigSource(config *rest.Config, opts cache.Options, namespaces []string,
+	name, key string) (*SecretCNIConfigSource, error) {
+	if len(namespaces) == 0 {
+		return nil, errors.New("Linkerd CNI namespaces of the Secret are not set")
+	}
+
+	opts.SelectorsByObject = cache.SelectorsByObject{
+		&corev1.Secret{}: {Field: fields.OneTermEqualSelector("metadata.name", name)},
+	}
+
+	secretCache, err := cache.MultiNamespacedCacheBuilder(namespaces)(config, opts)
+	if err != nil {
+		return nil, fmt.Errorf("can not create Linkerd-CNI Secret cache: %w", err)
+	}
+
+	return &SecretCNIConfigSource{Cache: secretCache, Name: name, Key: key, Namespaces: namespaces}, nil
+}
+
+// Get implements CNIConfigSource.
+func (s *SecretCNIConfigSource) Get(ctx context.Context, cniNamespace string) (string, error) {
+	var secret = &corev1.Secret{}
+
+	if !containsString(s.Namespaces, cniNamespace) {
+		return "", fmt.Errorf("%w: Secret %s/%s, the Secret is read only in: %s", ErrCNIConfigNamespaceNotCached,
+			cniNamespace, s.Name, strings.Join(s.Namespaces, ", "))
+	}
+
+	if err := s.Cache.Get(ctx, types.NamespacedName{Namespace: cniNamespace, Name: s.Name}, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			cniConfigLoadErrorsTotal.WithLabelValues(cniConfigErrorSecretNotFound).Inc()
+
+			return "", fmt.Errorf("%w: Secret %s/%s", ErrCNIConfigNotFound, cniNamespace, s.Name)
+		}
+
+		return "", fmt.Errorf("can not get Linkerd-CNI Secret %s/%s: %w", cniNamespace, s.Name, err)
+	}
+
+	raw, ok := secret.Data[s.Key]
+	if !ok {
+		cniConfigLoadErrorsTotal.WithLabelValues(cniConfigErrorKeyNotFound).Inc()
+
+		return "", fmt.Errorf("%w: key %s of Secret %s/%s", ErrCNIConfigKeyNotFound, s.Key, cniNamespace, s.Name)
+	}
+
+	return string(raw), nil
+}
+
+// Describe implements CNIConfigSource.
+func (s *SecretCNIConfigSource) Describe(cniNamespace string) string {
+	return fmt.Sprintf("Secret %s/%s key %s", cniNamespace, s.Name, s.Key)
+}
+
+// Watch implements CNIConfigSource.
+func (s *SecretCNIConfigSource) Watch(blder *builder.Builder,
+	mapFunc func(cniNamespace string) []reconcile.Request) *builder.Builder {
+	return blder.Watches(
+		source.NewKindWithCache(&corev1.Secret{}, s.Cache),
+		handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
+			return mapFunc(o.GetNamespace())
+		}),
+		builder.WithPredicates(getObjectNameEventFilter(s.Name)),
+	)
+}
+
+// Start runs the Secret cache until the context is cancelled.
+func (s *SecretCNIConfigSource) Start(ctx context.Context) error {
+	return s.Cache.Start(ctx)
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. The cache is filled on all the replicas
+// as the manager's one is.
+func (s *SecretCNIConfigSource) NeedLeaderElection() bool {
+	return false
+}
+
+// FileCNIConfigSource reads the Linkerd CNI configuration of all the meshes from a local file.
+// It must be added to the manager to notify the watching controllers about the file changes.
+type FileCNIConfigSource struct {
+	// Path is the path of the file.
+	Path string
+	// PollInterval is the period of the file change checks.
+	PollInterval time.Duration
+
+	mu          sync.Mutex
+	subscribers []chan event.GenericEvent
+}
+
+// Get implements CNIConfigSource.
+func (s *FileCNIConfigSource) Get(_ context.Context, _ string) (string, error) {
+	raw, err := os.ReadFile(s.Path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			cniConfigLoadErrorsTotal.WithLabelValues(cniConfigErrorFileNotFound).Inc()
+
+			return "", fmt.Errorf("%w: file %s", ErrCNIConfigNotFound, s.Path)
+		}
+
+		return "", fmt.Errorf("can not read Linkerd-CNI configuration file %s: %w", s.Path, err)
+	}
+
+	return string(raw), nil
+}
+
+// Describe implements CNIConfigSource.
+func (s *FileCNIConfigSource) Describe(string) string {
+	return "file " + s.Path
+}
+
+// Watch implements CNIConfigSource.
+func (s *FileCNIConfigSource) Watch(blder *builder.Builder,
+	mapFunc func(cniNamespace string) []reconcile.Request) *builder.Builder {
+	// A change is a single event, so the changes the controller has not consumed yet are coalesced.
+	events := make(chan event.GenericEvent, 1)
+
+	s.mu.Lock()
+	s.subscribers = append(s.subscribers, events)
+	s.mu.Unlock()
+
+	return blder.Watches(
+		&source.Channel{Source: events},
+		handler.EnqueueRequestsFromMapFunc(func(client.Object) []reconcile.Request {
+			return mapFunc("")
+		}),
+	)
+}
+
+// Start polls the file until the context is cancelled and notifies the watching controllers
+// when its content changes. The first read is not a change as the controllers handle all the objects on start.
+func (s *FileCNIConfigSource) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("cni-config-file").WithValues("path", s.Path)
+
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+
+	lastHash := s.hash()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			hash := s.hash()
+			if hash == lastHash {
+				continue
+			}
+
+			lastHash = hash
+
+			logger.Info("Linkerd CNI configuration file changed")
+			s.notify()
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. The file is polled on all the replicas,
+// the events are not consumed until the controllers are started on the elected one.
+func (s *FileCNIConfigSource) NeedLeaderElection() bool {
+	return false
+}
+
+// hash returns the hash of the file content, empty if the file can not be read.
+func (s *FileCNIConfigSource) hash() string {
+	raw, err := os.ReadFile(s.Path)
+	if err != nil {
+		return ""
+	}
+
+	hash := sha256.Sum256(raw)
+
+	return string(hash[:])
+}
+
+// notify sends a change event to every watching controller without blocking.
+func (s *FileCNIConfigSource) notify() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := event.GenericEvent{
+		Object: &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: s.Path}},
+	}
+
+	for _, events := range s.subscribers {
+		select {
+		case events <- changed:
+		default:
+		}
+	}
+}
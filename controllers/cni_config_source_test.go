@@ -0,0 +1,235 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+const testCNIConfig = `{"type":"linkerd-cni"}`
+
+// testSecretCache is a Secret cache which reads the Secrets with a fake client.
+type testSecretCache struct {
+	cache.Cache
+	reader client.Reader
+}
+
+// Get implements client.Reader.
+func (c *testSecretCache) Get(ctx context.Context, key client.ObjectKey, obj client.Object,
+	opts ...client.GetOption) error {
+	return c.reader.Get(ctx, key, obj, opts...)
+}
+
+func TestConfigMapCNIConfigSourceGet(t *testing.T) {
+	tests := []struct {
+		name        string
+		objects     []runtime.Object
+		namespaces  []string
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "configuration",
+			objects:  []runtime.Object{newTestCNIConfigMap("linkerd-cni", map[string]string{"config.json": testCNIConfig})},
+			expected: testCNIConfig,
+		},
+		{
+			name:        "no ConfigMap",
+			expectedErr: ErrCNIConfigNotFound,
+		},
+		{
+			name:        "no key",
+			objects:     []runtime.Object{newTestCNIConfigMap("linkerd-cni", map[string]string{"other.json": testCNIConfig})},
+			expectedErr: ErrCNIConfigKeyNotFound,
+		},
+		{
+			name:        "namespace is not cached",
+			objects:     []runtime.Object{newTestCNIConfigMap("linkerd-cni", map[string]string{"config.json": testCNIConfig})},
+			namespaces:  []string{"linkerd"},
+			expectedErr: ErrCNIConfigNamespaceNotCached,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &ConfigMapCNIConfigSource{
+				Client:     fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(tt.objects...).Build(),
+				Name:       "linkerd-cni-config",
+				Key:        "config.json",
+				Namespaces: tt.namespaces,
+			}
+
+			got, err := s.Get(context.Background(), "linkerd-cni")
+			if !errors.Is(err, tt.expectedErr) || (err != nil) != (tt.expectedErr != nil) {
+				t.Fatalf("Get() error = %v, want %v", err, tt.expectedErr)
+			}
+
+			if got != tt.expected {
+				t.Errorf("Get() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSecretCNIConfigSourceGet(t *testing.T) {
+	tests := []struct {
+		name        string
+		objects     []runtime.Object
+		namespace   string
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:      "configuration",
+			objects:   []runtime.Object{newTestCNISecret("linkerd-cni", map[string][]byte{"config.json": []byte(testCNIConfig)})},
+			namespace: "linkerd-cni",
+			expected:  testCNIConfig,
+		},
+		{
+			name:        "no Secret",
+			namespace:   "linkerd-cni",
+			expectedErr: ErrCNIConfigNotFound,
+		},
+		{
+			name:        "no key",
+			objects:     []runtime.Object{newTestCNISecret("linkerd-cni", map[string][]byte{"other.json": []byte(testCNIConfig)})},
+			namespace:   "linkerd-cni",
+			expectedErr: ErrCNIConfigKeyNotFound,
+		},
+		{
+			name:        "namespace is not cached",
+			objects:     []runtime.Object{newTestCNISecret("linkerd", map[string][]byte{"config.json": []byte(testCNIConfig)})},
+			namespace:   "linkerd",
+			expectedErr: ErrCNIConfigNamespaceNotCached,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &SecretCNIConfigSource{
+				Cache: &testSecretCache{
+					reader: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(tt.objects...).Build(),
+				},
+				Name:       "linkerd-cni-config",
+				Key:        "config.json",
+				Namespaces: []string{"linkerd-cni"},
+			}
+
+			got, err := s.Get(context.Background(), tt.namespace)
+			if !errors.Is(err, tt.expectedErr) || (err != nil) != (tt.expectedErr != nil) {
+				t.Fatalf("Get() error = %v, want %v", err, tt.expectedErr)
+			}
+
+			if got != tt.expected {
+				t.Errorf("Get() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFileCNIConfigSourceGet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	s := &FileCNIConfigSource{Path: path}
+
+	if _, err := s.Get(context.Background(), "linkerd-cni"); !errors.Is(err, ErrCNIConfigNotFound) {
+		t.Errorf("Get() error = %v, want %v", err, ErrCNIConfigNotFound)
+	}
+
+	writeTestFile(t, path, testCNIConfig)
+
+	got, err := s.Get(context.Background(), "linkerd-cni")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if got != testCNIConfig {
+		t.Errorf("Get() = %q, want %q", got, testCNIConfig)
+	}
+}
+
+func TestFileCNIConfigSourceStart(t *testing.T) {
+	const pollInterval = 10 * time.Millisecond
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeTestFile(t, path, testCNIConfig)
+
+	// The buffer is larger than the Watch's one so that the redundant notifications are not coalesced.
+	events := make(chan event.GenericEvent, 10)
+	s := &FileCNIConfigSource{Path: path, PollInterval: pollInterval, subscribers: []chan event.GenericEvent{events}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		if err := s.Start(ctx); err != nil {
+			t.Errorf("Start() error = %v", err)
+		}
+	}()
+
+	expectEvents := func(expected int) {
+		t.Helper()
+
+		time.Sleep(10 * pollInterval)
+
+		if got := len(events); got != expected {
+			t.Fatalf("notifications = %d, want %d", got, expected)
+		}
+
+		for i := 0; i < expected; i++ {
+			<-events
+		}
+	}
+
+	// The first read is not a change.
+	expectEvents(0)
+
+	writeTestFile(t, path, testCNIConfig)
+	expectEvents(0)
+
+	writeTestFile(t, path, `{"type":"linkerd-cni","log_level":"debug"}`)
+	expectEvents(1)
+}
+
+// newTestCNIConfigMap returns the Linkerd CNI configuration ConfigMap in the namespace.
+func newTestCNIConfigMap(namespace string, data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "linkerd-cni-config"},
+		Data:       data,
+	}
+}
+
+// newTestCNISecret returns the Linkerd CNI configuration Secret in the namespace.
+func newTestCNISecret(namespace string, data map[string][]byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "linkerd-cni-config"},
+		Data:       data,
+	}
+}
+
+// writeTestFile replaces the file atomically as the kubelet does with the mounted ConfigMaps and Secrets,
+// so the poller never reads a partially written file.
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	tmp := path + ".tmp"
+
+	if err := os.WriteFile(tmp, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrCNIConfigInvalid is an error which is returned when the Linkerd CNI configuration
+// does not pass the validation. The returned error is a *CNIConfigValidationError.
+var ErrCNIConfigInvalid = errors.New("Linkerd CNI configuration is invalid")
+
+// rootUID is the UID of the root user which Linkerd proxy must not run as.
+const rootUID = 0
+
+// portRangeMessage is the validation message of a port out of the valid range.
+const portRangeMessage = "port is out of 1-65535 range"
+
+// CNIConfigFieldError is a validation error of a Linkerd CNI configuration field.
+type CNIConfigFieldError struct {
+	// Field is the JSON path of the field, e.g. "linkerd.proxy-uid".
+	Field string
+	// Value is the invalid value.
+	Value interface{}
+	// Reason describes why the value is invalid.
+	Reason string
+}
+
+func (e *CNIConfigFieldError) Error() string {
+	return fmt.Sprintf("%s: %s (value %s)", e.Field, e.Reason, formatJSONValue(e.Value))
+}
+
+// CNIConfigValidationError contains all the validation errors of a Linkerd CNI configuration.
+type CNIConfigValidationError struct {
+	Errors []*CNIConfigFieldError
+}
+
+func (e *CNIConfigValidationError) Error() string {
+	var messages = make([]string, 0, len(e.Errors))
+
+	for _, fieldErr := range e.Errors {
+		messages = append(messages, fieldErr.Error())
+	}
+
+	return ErrCNIConfigInvalid.Error() + ": " + strings.Join(messages, "; ")
+}
+
+// Is makes the error match ErrCNIConfigInvalid.
+func (e *CNIConfigValidationError) Is(target error) bool {
+	return target == ErrCNIConfigInvalid
+}
+
+// validateCNIPluginConf checks the Linkerd CNI configuration fields which would break the Pods
+// started with it. Returns nil or *CNIConfigValidationError with all the invalid fields.
+func validateCNIPluginConf(config *CNIPluginConf) error {
+	var fieldErrs []*CNIConfigFieldError
+
+	invalid := func(field string, value interface{}, reason string) {
+		fieldErrs = append(fieldErrs, &CNIConfigFieldError{Field: field, Value: value, Reason: reason})
+	}
+
+	if config.Type == "" {
+		invalid("type", config.Type, "plugin type must not be empty")
+	}
+
+	if config.Kubernetes.Kubeconfig == "" {
+		invalid("kubernetes.kubeconfig", config.Kubernetes.Kubeconfig, "Kubeconfig path must not be empty")
+	}
+
+	if config.LogLevel != "" && !isKnownLogLevel(config.LogLevel) {
+		invalid("log_level", config.LogLevel, "expected one of: "+strings.Join(cniLogLevels, ", "))
+	}
+
+	if !isValidPort(config.Linkerd.IncomingProxyPort) {
+		invalid("linkerd.incoming-proxy-port", config.Linkerd.IncomingProxyPort, portRangeMessage)
+	}
+
+	if !isValidPort(config.Linkerd.OutgoingProxyPort) {
+		invalid("linkerd.outgoing-proxy-port", config.Linkerd.OutgoingProxyPort, portRangeMessage)
+	}
+
+	switch {
+	case config.Linkerd.ProxyUID < rootUID:
+		invalid("linkerd.proxy-uid", config.Linkerd.ProxyUID, "UID must not be negative")
+	case config.Linkerd.ProxyUID == rootUID:
+		invalid("linkerd.proxy-uid", config.Linkerd.ProxyUID, "Linkerd proxy must not run as root")
+	}
+
+	for i, port := range config.Linkerd.PortsToRedirect {
+		if !isValidPort(port) {
+			invalid(fmt.Sprintf("linkerd.ports-to-redirect[%d]", i), port, portRangeMessage)
+		}
+	}
+
+	for i, ports := range config.Linkerd.InboundPortsToIgnore {
+		if err := validatePortRange(ports); err != nil {
+			invalid(fmt.Sprintf("linkerd.inbound-ports-to-ignore[%d]", i), ports, err.Error())
+		}
+	}
+
+	for i, ports := range config.Linkerd.OutboundPortsToIgnore {
+		if err := validatePortRange(ports); err != nil {
+			invalid(fmt.Sprintf("linkerd.outbound-ports-to-ignore[%d]", i), ports, err.Error())
+		}
+	}
+
+	if len(fieldErrs) != 0 {
+		return &CNIConfigValidationError{Errors: fieldErrs}
+	}
+
+	return nil
+}
+
+// isValidPort checks if a port is in the 1-65535 range.
+func isValidPort(port int) bool {
+	return 1 <= port && port <= 65535
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidateCNIPluginConf(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         func(c *CNIPluginConf)
+		expectedFields []string
+	}{
+		{
+			name:   "valid",
+			config: func(c *CNIPluginConf) {},
+		},
+		{
+			name: "valid log level and port ranges",
+			config: func(c *CNIPluginConf) {
+				c.LogLevel = "debug"
+				c.Linkerd.PortsToRedirect = []int{80, 443}
+				c.Linkerd.OutboundPortsToIgnore = []string{"443", "8080-8090"}
+			},
+		},
+		{
+			name:           "empty type",
+			config:         func(c *CNIPluginConf) { c.Type = "" },
+			expectedFields: []string{"type"},
+		},
+		{
+			name:           "empty Kubeconfig path",
+			config:         func(c *CNIPluginConf) { c.Kubernetes.Kubeconfig = "" },
+			expectedFields: []string{"kubernetes.kubeconfig"},
+		},
+		{
+			name:           "unknown log level",
+			config:         func(c *CNIPluginConf) { c.LogLevel = "verbose" },
+			expectedFields: []string{"log_level"},
+		},
+		{
+			name: "proxy ports out of range",
+			config: func(c *CNIPluginConf) {
+				c.Linkerd.IncomingProxyPort = 0
+				c.Linkerd.OutgoingProxyPort = 65536
+			},
+			expectedFields: []string{"linkerd.incoming-proxy-port", "linkerd.outgoing-proxy-port"},
+		},
+		{
+			name:           "root proxy UID",
+			config:         func(c *CNIPluginConf) { c.Linkerd.ProxyUID = 0 },
+			expectedFields: []string{"linkerd.proxy-uid"},
+		},
+		{
+			name:           "negative proxy UID",
+			config:         func(c *CNIPluginConf) { c.Linkerd.ProxyUID = -1 },
+			expectedFields: []string{"linkerd.proxy-uid"},
+		},
+		{
+			name:           "port to redirect out of range",
+			config:         func(c *CNIPluginConf) { c.Linkerd.PortsToRedirect = []int{80, 70000} },
+			expectedFields: []string{"linkerd.ports-to-redirect[1]"},
+		},
+		{
+			name: "invalid ports to ignore",
+			config: func(c *CNIPluginConf) {
+				c.Linkerd.InboundPortsToIgnore = []string{"25", "http"}
+				c.Linkerd.OutboundPortsToIgnore = []string{"8090-8080"}
+			},
+			expectedFields: []string{"linkerd.inbound-ports-to-ignore[1]", "linkerd.outbound-ports-to-ignore[0]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestCNIPluginConf()
+			tt.config(config)
+
+			err := validateCNIPluginConf(config)
+
+			if tt.expectedFields == nil {
+				if err != nil {
+					t.Errorf("validateCNIPluginConf() error = %v, want nil", err)
+				}
+
+				return
+			}
+
+			var validationErr *CNIConfigValidationError
+			if !errors.As(err, &validationErr) || !errors.Is(err, ErrCNIConfigInvalid) {
+				t.Fatalf("validateCNIPluginConf() error = %v, want %T", err, validationErr)
+			}
+
+			var fields = make([]string, 0, len(validationErr.Errors))
+
+			for _, fieldErr := range validationErr.Errors {
+				fields = append(fields, fieldErr.Field)
+			}
+
+			if !reflect.DeepEqual(fields, tt.expectedFields) {
+				t.Errorf("validateCNIPluginConf() invalid fields = %q, want %q", fields, tt.expectedFields)
+			}
+		})
+	}
+}
+
+func TestCNIConfigValidationErrorMessage(t *testing.T) {
+	err := &CNIConfigValidationError{Errors: []*CNIConfigFieldError{
+		{Field: "linkerd.proxy-uid", Value: 0, Reason: "Linkerd proxy must not run as root"},
+		{Field: "log_level", Value: "verbose", Reason: "unknown log level"},
+	}}
+
+	expected := ErrCNIConfigInvalid.Error() + `: linkerd.proxy-uid: Linkerd proxy must not run as root (value 0); ` +
+		`log_level: unknown log level (value "verbose")`
+
+	if got := err.Error(); got != expected {
+		t.Errorf("Error() = %q, want %q", got, expected)
+	}
+}
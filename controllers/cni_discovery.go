@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8s "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
+	"github.com/ErmakovDmitriy/linkerd-multus-attach-operator/settings"
+)
+
+// ErrCNIChained is returned when the Linkerd CNI of a mesh is chained into the primary CNI configuration,
+// so attaching it with Multus again would apply the iptables rules twice.
+var ErrCNIChained = errors.New("Linkerd CNI is installed in chained mode")
+
+// CNIInstallation is a Linkerd CNI installation found by its DaemonSet.
+type CNIInstallation struct {
+	// DaemonSet is the Linkerd CNI DaemonSet.
+	DaemonSet types.NamespacedName
+	// ConfigMap is the name of the ConfigMap the installer gets the Linkerd CNI config from,
+	// empty if the installer does not use a ConfigMap.
+	ConfigMap string
+	// ConfigMapKey is the ConfigMap key with the Linkerd CNI config.
+	ConfigMapKey string
+	// NetDir is the host directory the installer writes the CNI configuration to.
+	NetDir string
+	// IsChained is true if the installer chains Linkerd CNI into the primary CNI configuration.
+	IsChained bool
+}
+
+// CNIDiscovery finds the Linkerd CNI installations by their DaemonSets in any namespace.
+type CNIDiscovery struct {
+	Client client.Reader
+	// PrimaryNetDirs are the host directories of the primary CNI configuration.
+	// Linkerd CNI installed into one of them is chained into the configuration of all the Pods.
+	PrimaryNetDirs []string
+}
+
+// Discover returns the Linkerd CNI installation of a mesh: the one in the mesh's Linkerd CNI namespace,
+// in the mesh's control plane namespace or the only one in the cluster. Returns nil if there is none.
+func (d *CNIDiscovery) Discover(ctx context.Context, mesh *settings.Mesh) (*CNIInstallation, error) {
+	var daemonSets = &appsv1.DaemonSetList{}
+
+	if err := d.Client.List(ctx, daemonSets,
+		client.MatchingLabels{k8s.LinkerdCNIDaemonSetLabel: k8s.LinkerdCNIDaemonSetLabelValue}); err != nil {
+		return nil, fmt.Errorf("can not list Linkerd CNI DaemonSets: %w", err)
+	}
+
+	for _, namespace := range []string{mesh.CNINamespace, mesh.LinkerdNamespace} {
+		for i := range daemonSets.Items {
+			if daemonSets.Items[i].Namespace == namespace {
+				return d.newCNIInstallation(&daemonSets.Items[i]), nil
+			}
+		}
+	}
+
+	if len(daemonSets.Items) == 1 {
+		return d.newCNIInstallation(&daemonSets.Items[0]), nil
+	}
+
+	return nil, nil
+}
+
+// newCNIInstallation reads the Linkerd CNI installation parameters from the installer container:
+// the container which gets the Linkerd CNI config by the LinkerdCNINetworkConfigEnv variable.
+func (d *CNIDiscovery) newCNIInstallation(daemonSet *appsv1.DaemonSet) *CNIInstallation {
+	var installation = &CNIInstallation{
+		DaemonSet: client.ObjectKeyFromObject(daemonSet),
+	}
+
+	podSpec := &daemonSet.Spec.Template.Spec
+
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+
+		configEnv := findEnv(container.Env, k8s.LinkerdCNINetworkConfigEnv)
+		if configEnv == nil {
+			continue
+		}
+
+		if configEnv.ValueFrom != nil && configEnv.ValueFrom.ConfigMapKeyRef != nil {
+			installation.ConfigMap = configEnv.ValueFrom.ConfigMapKeyRef.Name
+			installation.ConfigMapKey = configEnv.ValueFrom.ConfigMapKeyRef.Key
+		}
+
+		installation.NetDir = installerNetDir(podSpec, container)
+
+		break
+	}
+
+	for _, primaryNetDir := range d.PrimaryNetDirs {
+		if installation.NetDir != "" && path.Clean(installation.NetDir) == path.Clean(primaryNetDir) {
+			installation.IsChained = true
+		}
+	}
+
+	return installation
+}
+
+// installerNetDir returns the host directory the Linkerd CNI installer writes the CNI configuration to:
+// the LinkerdCNINetDirEnv value or the host path of the volume mounted at LinkerdCNIHostNetDirMountPath.
+func installerNetDir(podSpec *corev1.PodSpec, container *corev1.Container) string {
+	if netDirEnv := findEnv(container.Env, k8s.LinkerdCNINetDirEnv); netDirEnv != nil && netDirEnv.Value != "" {
+		return netDirEnv.Value
+	}
+
+	for _, mount := range container.VolumeMounts {
+		if mount.MountPath != k8s.LinkerdCNIHostNetDirMountPath {
+			continue
+		}
+
+		for i := range podSpec.Volumes {
+			if podSpec.Volumes[i].Name == mount.Name && podSpec.Volumes[i].HostPath != nil {
+				return podSpec.Volumes[i].HostPath.Path
+			}
+		}
+	}
+
+	return ""
+}
+
+func findEnv(env []corev1.EnvVar, name string) *corev1.EnvVar {
+	for i := range env {
+		if env[i].Name == name {
+			return &env[i]
+		}
+	}
+
+	return nil
+}
+
+// resolveCNIConfigSource returns the Linkerd CNI configuration source and namespace of a mesh.
+// Without the discovery, or if no installation is found, the configured ones are returned.
+// Returns ErrCNIChained if the mesh's Linkerd CNI is chained into the primary CNI configuration.
+func resolveCNIConfigSource(ctx context.Context, source CNIConfigSource, discovery *CNIDiscovery,
+	mesh *settings.Mesh) (CNIConfigSource, string, error) {
+	if discovery == nil {
+		return source, mesh.CNINamespace, nil
+	}
+
+	installation, err := discovery.Discover(ctx, mesh)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if installation == nil {
+		return source, mesh.CNINamespace, nil
+	}
+
+	if installation.IsChained {
+		return nil, "", fmt.Errorf("%w: DaemonSet %s writes the CNI configuration to the primary CNI directory %s, "+
+			"attaching it with Multus would apply the iptables rules twice", ErrCNIChained,
+			installation.DaemonSet.String(), installation.NetDir)
+	}
+
+	// The ConfigMap the installer uses wins over the configured one.
+	if configMapSource, ok := source.(*ConfigMapCNIConfigSource); ok && installation.ConfigMap != "" {
+		source = &ConfigMapCNIConfigSource{
+			Client:     configMapSource.Client,
+			Name:       installation.ConfigMap,
+			Key:        installation.ConfigMapKey,
+			Namespaces: configMapSource.Namespaces,
+		}
+	}
+
+	return source, installation.DaemonSet.Namespace, nil
+}
+
+// loadMeshCNIConfig loads the Linkerd CNI configuration of a mesh from its configured
+// or discovered source.
+func loadMeshCNIConfig(ctx context.Context, source CNIConfigSource, discovery *CNIDiscovery,
+	mesh *settings.Mesh) (*CNIPluginConf, error) {
+	meshSource, cniNamespace, err := resolveCNIConfigSource(ctx, source, discovery, mesh)
+	if err != nil {
+		return nil, err
+	}
+
+	return getCNINetworkConfig(ctx, meshSource, cniNamespace, mesh.CNIKubeconfigPath)
+}
@@ -0,0 +1,282 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	k8s "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
+	"github.com/ErmakovDmitriy/linkerd-multus-attach-operator/settings"
+)
+
+const testHostNetDirVolume = "cni-net-dir"
+
+var testMesh = settings.Mesh{LinkerdNamespace: "linkerd", CNINamespace: "linkerd-cni"}
+
+// newTestCNIDaemonSet returns a Linkerd CNI DaemonSet which installer gets the configuration
+// from the configMap and writes it to the netDir host directory.
+func newTestCNIDaemonSet(namespace, configMap, netDir string) *appsv1.DaemonSet {
+	daemonSet := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "linkerd-cni",
+			Namespace: namespace,
+			Labels:    map[string]string{k8s.LinkerdCNIDaemonSetLabel: k8s.LinkerdCNIDaemonSetLabelValue},
+		},
+	}
+
+	daemonSet.Spec.Template.Spec = corev1.PodSpec{
+		Containers: []corev1.Container{
+			{Name: "sidecar"},
+			{
+				Name: "install-cni",
+				Env: []corev1.EnvVar{{
+					Name: k8s.LinkerdCNINetworkConfigEnv,
+					ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: configMap},
+						Key:                  k8s.LinkerdCNIConfigMapKey,
+					}},
+				}},
+				VolumeMounts: []corev1.VolumeMount{{Name: testHostNetDirVolume, MountPath: k8s.LinkerdCNIHostNetDirMountPath}},
+			},
+		},
+		Volumes: []corev1.Volume{{
+			Name:         testHostNetDirVolume,
+			VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: netDir}},
+		}},
+	}
+
+	return daemonSet
+}
+
+func TestInstallerNetDir(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      []corev1.EnvVar
+		mounts   []corev1.VolumeMount
+		expected string
+	}{
+		{
+			name:     "environment variable wins over the volume",
+			env:      []corev1.EnvVar{{Name: k8s.LinkerdCNINetDirEnv, Value: "/etc/cni/multus/net.d"}},
+			mounts:   []corev1.VolumeMount{{Name: testHostNetDirVolume, MountPath: k8s.LinkerdCNIHostNetDirMountPath}},
+			expected: "/etc/cni/multus/net.d",
+		},
+		{
+			name:     "empty environment variable",
+			env:      []corev1.EnvVar{{Name: k8s.LinkerdCNINetDirEnv}},
+			mounts:   []corev1.VolumeMount{{Name: testHostNetDirVolume, MountPath: k8s.LinkerdCNIHostNetDirMountPath}},
+			expected: "/etc/cni/net.d",
+		},
+		{
+			name:   "volume mounted at other path",
+			mounts: []corev1.VolumeMount{{Name: testHostNetDirVolume, MountPath: "/host/opt/cni/bin"}},
+		},
+		{
+			name:   "not host path volume",
+			mounts: []corev1.VolumeMount{{Name: "config", MountPath: k8s.LinkerdCNIHostNetDirMountPath}},
+		},
+		{
+			name: "no volume",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			podSpec := &corev1.PodSpec{
+				Volumes: []corev1.Volume{
+					{
+						Name:         testHostNetDirVolume,
+						VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/etc/cni/net.d"}},
+					},
+					{
+						Name:         "config",
+						VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+					},
+				},
+			}
+			container := &corev1.Container{Env: tt.env, VolumeMounts: tt.mounts}
+
+			if got := installerNetDir(podSpec, container); got != tt.expected {
+				t.Errorf("installerNetDir() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCNIDiscoveryDiscover(t *testing.T) {
+	notLabeled := newTestCNIDaemonSet("linkerd-cni", "linkerd-cni-config", "/etc/cni/multus/net.d")
+	notLabeled.Labels = nil
+
+	tests := []struct {
+		name       string
+		daemonSets []runtime.Object
+		expected   *CNIInstallation
+	}{
+		{
+			name: "no installation",
+		},
+		{
+			name:       "not labeled DaemonSet",
+			daemonSets: []runtime.Object{notLabeled},
+		},
+		{
+			name: "Linkerd CNI namespace wins over the control plane namespace",
+			daemonSets: []runtime.Object{
+				newTestCNIDaemonSet("linkerd", "linkerd-config", "/etc/cni/multus/net.d"),
+				newTestCNIDaemonSet("linkerd-cni", "linkerd-cni-config", "/etc/cni/multus/net.d"),
+			},
+			expected: &CNIInstallation{
+				DaemonSet:    types.NamespacedName{Namespace: "linkerd-cni", Name: "linkerd-cni"},
+				ConfigMap:    "linkerd-cni-config",
+				ConfigMapKey: k8s.LinkerdCNIConfigMapKey,
+				NetDir:       "/etc/cni/multus/net.d",
+			},
+		},
+		{
+			name: "control plane namespace",
+			daemonSets: []runtime.Object{
+				newTestCNIDaemonSet("linkerd", "linkerd-config", "/etc/cni/multus/net.d"),
+				newTestCNIDaemonSet("other", "other-config", "/etc/cni/multus/net.d"),
+			},
+			expected: &CNIInstallation{
+				DaemonSet:    types.NamespacedName{Namespace: "linkerd", Name: "linkerd-cni"},
+				ConfigMap:    "linkerd-config",
+				ConfigMapKey: k8s.LinkerdCNIConfigMapKey,
+				NetDir:       "/etc/cni/multus/net.d",
+			},
+		},
+		{
+			name:       "the only installation in the cluster",
+			daemonSets: []runtime.Object{newTestCNIDaemonSet("other", "other-config", "/etc/cni/multus/net.d")},
+			expected: &CNIInstallation{
+				DaemonSet:    types.NamespacedName{Namespace: "other", Name: "linkerd-cni"},
+				ConfigMap:    "other-config",
+				ConfigMapKey: k8s.LinkerdCNIConfigMapKey,
+				NetDir:       "/etc/cni/multus/net.d",
+			},
+		},
+		{
+			name: "several installations of other meshes",
+			daemonSets: []runtime.Object{
+				newTestCNIDaemonSet("other-a", "other-config", "/etc/cni/multus/net.d"),
+				newTestCNIDaemonSet("other-b", "other-config", "/etc/cni/multus/net.d"),
+			},
+		},
+		{
+			name:       "chained into the primary CNI configuration",
+			daemonSets: []runtime.Object{newTestCNIDaemonSet("linkerd-cni", "linkerd-cni-config", "/etc/cni/net.d/")},
+			expected: &CNIInstallation{
+				DaemonSet:    types.NamespacedName{Namespace: "linkerd-cni", Name: "linkerd-cni"},
+				ConfigMap:    "linkerd-cni-config",
+				ConfigMapKey: k8s.LinkerdCNIConfigMapKey,
+				NetDir:       "/etc/cni/net.d/",
+				IsChained:    true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			discovery := &CNIDiscovery{
+				Client:         fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(tt.daemonSets...).Build(),
+				PrimaryNetDirs: []string{"/etc/cni/net.d"},
+			}
+
+			got, err := discovery.Discover(context.Background(), &testMesh)
+			if err != nil {
+				t.Fatalf("Discover() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Discover() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResolveCNIConfigSource(t *testing.T) {
+	configMapSource := &ConfigMapCNIConfigSource{
+		Name:       k8s.LinkerdCNIConfigMapName,
+		Key:        k8s.LinkerdCNIConfigMapKey,
+		Namespaces: []string{"linkerd", "linkerd-cni"},
+	}
+	fileSource := &FileCNIConfigSource{Path: "/etc/linkerd-cni/config.json"}
+
+	newDiscovery := func(daemonSets ...runtime.Object) *CNIDiscovery {
+		return &CNIDiscovery{
+			Client:         fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(daemonSets...).Build(),
+			PrimaryNetDirs: []string{"/etc/cni/net.d"},
+		}
+	}
+
+	tests := []struct {
+		name              string
+		source            CNIConfigSource
+		discovery         *CNIDiscovery
+		expectedSource    CNIConfigSource
+		expectedNamespace string
+	}{
+		{
+			name:              "no discovery",
+			source:            configMapSource,
+			expectedSource:    configMapSource,
+			expectedNamespace: testMesh.CNINamespace,
+		},
+		{
+			name:              "no installation",
+			source:            configMapSource,
+			discovery:         newDiscovery(),
+			expectedSource:    configMapSource,
+			expectedNamespace: testMesh.CNINamespace,
+		},
+		{
+			name:      "discovered ConfigMap wins over the configured one",
+			source:    configMapSource,
+			discovery: newDiscovery(newTestCNIDaemonSet("linkerd", "linkerd-config", "/etc/cni/multus/net.d")),
+			expectedSource: &ConfigMapCNIConfigSource{
+				Name:       "linkerd-config",
+				Key:        k8s.LinkerdCNIConfigMapKey,
+				Namespaces: configMapSource.Namespaces,
+			},
+			expectedNamespace: "linkerd",
+		},
+		{
+			name:              "other source is kept",
+			source:            fileSource,
+			discovery:         newDiscovery(newTestCNIDaemonSet("linkerd", "linkerd-config", "/etc/cni/multus/net.d")),
+			expectedSource:    fileSource,
+			expectedNamespace: "linkerd",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, namespace, err := resolveCNIConfigSource(context.Background(), tt.source, tt.discovery, &testMesh)
+			if err != nil {
+				t.Fatalf("resolveCNIConfigSource() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(source, tt.expectedSource) || namespace != tt.expectedNamespace {
+				t.Errorf("resolveCNIConfigSource() = %+v, %q, want %+v, %q",
+					source, namespace, tt.expectedSource, tt.expectedNamespace)
+			}
+		})
+	}
+
+	t.Run("chained installation", func(t *testing.T) {
+		discovery := newDiscovery(newTestCNIDaemonSet("linkerd-cni", "linkerd-cni-config", "/etc/cni/net.d"))
+
+		if _, _, err := resolveCNIConfigSource(context.Background(), configMapSource, discovery, &testMesh); !errors.Is(err, ErrCNIChained) {
+			t.Errorf("resolveCNIConfigSource() error = %v, want %v", err, ErrCNIChained)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/containernetworking/cni/pkg/version"
+
+	"github.com/ErmakovDmitriy/linkerd-multus-attach-operator/settings"
+)
+
+// ReasonCNIVersionDowngraded is the reason of the Events about NetworkAttachmentDefinitions rendered
+// with an older CNI specification version than the one of the Linkerd CNI configuration.
+const ReasonCNIVersionDowngraded = "CNIVersionDowngraded"
+
+// ErrUnsupportedCNIVersion is returned when the NetworkAttachmentDefinitions can not be rendered
+// with a CNI specification version Linkerd CNI supports.
+var ErrUnsupportedCNIVersion = errors.New("unsupported CNI version")
+
+// LinkerdCNISupportedVersions are the CNI specification versions the Linkerd CNI plugin supports.
+// They are not the versions of the CNI library the operator is built with, which has newer ones.
+var LinkerdCNISupportedVersions = []string{"0.1.0", "0.2.0", "0.3.0", "0.4.0"}
+
+// supportedCNIVersions returns the CNI specification versions Linkerd CNI supports:
+// the configured ones or, if not configured, LinkerdCNISupportedVersions.
+func supportedCNIVersions(cfg settings.Settings) []string {
+	if len(cfg.CNISupportedVersions) != 0 {
+		return cfg.CNISupportedVersions
+	}
+
+	return LinkerdCNISupportedVersions
+}
+
+// negotiateCNIVersion returns the CNI specification version of the NetworkAttachmentDefinitions:
+// the configured target version or the version of the Linkerd CNI configuration.
+// The configured target version must be supported. The Linkerd CNI configuration version which is
+// not supported is downgraded to the newest older supported version, isDowngraded is true then.
+func negotiateCNIVersion(configVersion string, cfg settings.Settings) (cniVersion string, isDowngraded bool, err error) {
+	supported := supportedCNIVersions(cfg)
+
+	if cfg.CNIVersion != "" {
+		if !containsString(supported, cfg.CNIVersion) {
+			return "", false, fmt.Errorf("%w: configured cniVersion %q is not supported by Linkerd CNI, supported versions: %s",
+				ErrUnsupportedCNIVersion, cfg.CNIVersion, strings.Join(supported, ", "))
+		}
+
+		return cfg.CNIVersion, false, nil
+	}
+
+	if containsString(supported, configVersion) {
+		return configVersion, false, nil
+	}
+
+	if _, _, _, err := version.ParseVersion(configVersion); err != nil {
+		return "", false, fmt.Errorf("%w: Linkerd CNI configuration cniVersion %q: %s",
+			ErrUnsupportedCNIVersion, configVersion, err.Error())
+	}
+
+	for _, supportedVersion := range supported {
+		isOlder, err := version.GreaterThanOrEqualTo(configVersion, supportedVersion)
+		if err != nil || !isOlder {
+			continue
+		}
+
+		if cniVersion != "" {
+			if isNewer, err := version.GreaterThanOrEqualTo(supportedVersion, cniVersion); err != nil || !isNewer {
+				continue
+			}
+		}
+
+		cniVersion = supportedVersion
+	}
+
+	if cniVersion == "" {
+		return "", false, fmt.Errorf("%w: Linkerd CNI configuration cniVersion %q is older than the supported versions: %s",
+			ErrUnsupportedCNIVersion, configVersion, strings.Join(supported, ", "))
+	}
+
+	return cniVersion, true, nil
+}
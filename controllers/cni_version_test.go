@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ErmakovDmitriy/linkerd-multus-attach-operator/settings"
+)
+
+func TestSupportedCNIVersions(t *testing.T) {
+	if got := supportedCNIVersions(settings.Settings{}); !reflect.DeepEqual(got, LinkerdCNISupportedVersions) {
+		t.Errorf("supportedCNIVersions() = %q, want %q", got, LinkerdCNISupportedVersions)
+	}
+
+	configured := []string{"0.3.1", "1.0.0"}
+
+	if got := supportedCNIVersions(settings.Settings{CNISupportedVersions: configured}); !reflect.DeepEqual(got, configured) {
+		t.Errorf("supportedCNIVersions() = %q, want %q", got, configured)
+	}
+}
+
+func TestNegotiateCNIVersion(t *testing.T) {
+	tests := []struct {
+		name                 string
+		configVersion        string
+		cfg                  settings.Settings
+		expected             string
+		expectedIsDowngraded bool
+	}{
+		{"supported version", "0.3.0", settings.Settings{}, "0.3.0", false},
+		{"newer version is downgraded", "1.0.0", settings.Settings{}, "0.4.0", true},
+		{"unlisted version is downgraded to the newest older one", "0.3.1", settings.Settings{}, "0.3.0", true},
+		{"unordered supported versions", "1.0.0",
+			settings.Settings{CNISupportedVersions: []string{"0.4.0", "0.3.1", "0.1.0"}}, "0.4.0", true},
+		{"configured supported versions", "1.0.0",
+			settings.Settings{CNISupportedVersions: []string{"0.3.1", "1.0.0"}}, "1.0.0", false},
+		{"target version", "0.4.0", settings.Settings{CNIVersion: "0.3.0"}, "0.3.0", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, isDowngraded, err := negotiateCNIVersion(tt.configVersion, tt.cfg)
+			if err != nil {
+				t.Fatalf("negotiateCNIVersion() error = %v", err)
+			}
+
+			if got != tt.expected || isDowngraded != tt.expectedIsDowngraded {
+				t.Errorf("negotiateCNIVersion() = %q, %v, want %q, %v", got, isDowngraded, tt.expected, tt.expectedIsDowngraded)
+			}
+		})
+	}
+}
+
+func TestNegotiateCNIVersionUnsupported(t *testing.T) {
+	tests := []struct {
+		name          string
+		configVersion string
+		cfg           settings.Settings
+	}{
+		{"unsupported target version", "0.4.0", settings.Settings{CNIVersion: "1.0.0"}},
+		{"malformed version", "latest", settings.Settings{}},
+		{"version older than the supported ones", "0.2.0", settings.Settings{CNISupportedVersions: []string{"0.3.0"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := negotiateCNIVersion(tt.configVersion, tt.cfg); !errors.Is(err, ErrUnsupportedCNIVersion) {
+				t.Errorf("negotiateCNIVersion() error = %v, want %v", err, ErrUnsupportedCNIVersion)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	multusv1alpha1 "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/api/v1alpha1"
+	"github.com/ErmakovDmitriy/linkerd-multus-attach-operator/settings"
+	"github.com/go-logr/logr"
+	netattachv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+)
+
+// Kinds of the differences between the desired and the actual NetworkAttachmentDefinitions.
+const (
+	// DriftMissing - required NetworkAttachmentDefinition does not exist.
+	DriftMissing = "missing"
+	// DriftOutdated - managed NetworkAttachmentDefinition differs from the Linkerd CNI configuration.
+	DriftOutdated = "outdated"
+	// DriftStale - managed NetworkAttachmentDefinition is not required.
+	DriftStale = "stale"
+)
+
+// maxDriftedNamespaces limits the number of namespaces listed in the drift report.
+const maxDriftedNamespaces = 50
+
+// DriftDetector periodically compares the desired and the actual NetworkAttachmentDefinitions
+// in all namespaces and enqueues the namespaces which have drifted. It catches the changes
+// which do not trigger the NamespaceReconciler, e.g. made while the operator was down or
+// by someone who keeps fighting the operator. The next check reports the enqueued namespaces
+// which are no longer drifted as repaired.
+type DriftDetector struct {
+	client.Client
+	// Reconciler provides the configuration of the NamespaceReconciler which fixes the drifted namespaces.
+	Reconciler *NamespaceReconciler
+	// Events enqueues the drifted namespaces to the NamespaceReconciler, see NamespaceReconciler.DriftEvents.
+	Events chan<- event.GenericEvent
+	// Interval is the period of the drift checks.
+	Interval time.Duration
+
+	// enqueued are the namespaces enqueued by the last check.
+	enqueued map[string]bool
+}
+
+// Start runs the drift checks until the context is cancelled.
+// The first check is done after Interval as the NamespaceReconciler
+// handles all the namespaces on start.
+func (d *DriftDetector) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("drift-detector")
+
+	logger.Info("Starting periodic drift detection", "interval", d.Interval.String())
+
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := d.check(ctx, logger); err != nil {
+				driftChecksTotal.WithLabelValues("error").Inc()
+				logger.Error(err, "drift check failed")
+
+				continue
+			}
+
+			driftChecksTotal.WithLabelValues("success").Inc()
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable,
+// only the leader fixes the drifted namespaces.
+func (d *DriftDetector) NeedLeaderElection() bool {
+	return true
+}
+
+// check compares the desired and the actual state of all the namespaces, enqueues the drifted ones
+// and reports the result in the log, the metrics and the LinkerdMultusConfig status.
+func (d *DriftDetector) check(ctx context.Context, logger logr.Logger) error {
+	cfg, err := d.Reconciler.Settings.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("can not load operator settings: %w", err)
+	}
+
+	var namespaces = &corev1.NamespaceList{}
+
+	if err := d.List(ctx, namespaces); err != nil {
+		return fmt.Errorf("can not list Namespaces: %w", err)
+	}
+
+	var netAttaches = &netattachv1.NetworkAttachmentDefinitionList{}
+
+	if err := d.List(ctx, netAttaches); err != nil {
+		return fmt.Errorf("can not list Multus NetworkAttachmentDefinitions: %w", err)
+	}
+
+	netAttachesByNamespace := make(map[string][]*netattachv1.NetworkAttachmentDefinition, len(namespaces.Items))
+
+	for i := range netAttaches.Items {
+		netAttach := &netAttaches.Items[i]
+		netAttachesByNamespace[netAttach.Namespace] = append(netAttachesByNamespace[netAttach.Namespace], netAttach)
+	}
+
+	var (
+		report = &multusv1alpha1.DriftReport{}
+		// Linkerd CNI configurations by the mesh, nil if the configuration can not be loaded.
+		cniConfigs = make(map[string]*CNIPluginConf)
+		enqueued   = make(map[string]bool)
+	)
+
+	for i := range namespaces.Items {
+		ns := &namespaces.Items[i]
+
+		if ns.Status.Phase == corev1.NamespaceTerminating {
+			continue
+		}
+
+		report.CheckedNamespaces++
+
+		kinds := d.checkNamespace(ctx, logger, ns, cfg, netAttachesByNamespace[ns.Name], cniConfigs)
+		if len(kinds) == 0 {
+			if d.enqueued[ns.Name] {
+				driftRepairedTotal.Inc()
+
+				report.Repaired++
+			}
+
+			continue
+		}
+
+		for _, kind := range kinds {
+			driftDetectedTotal.WithLabelValues(kind).Inc()
+
+			switch kind {
+			case DriftMissing:
+				report.Missing++
+			case DriftOutdated:
+				report.Outdated++
+			case DriftStale:
+				report.Stale++
+			}
+		}
+
+		if len(report.DriftedNamespaces) < maxDriftedNamespaces {
+			report.DriftedNamespaces = append(report.DriftedNamespaces, ns.Name)
+		}
+
+		// Audit mode reports the drift only.
+		if cfg.IsAudit() {
+			logger.Info("Audit mode: Namespace has drifted", "namespace", ns.Name, "drift", kinds)
+
+			continue
+		}
+
+		logger.Info("Namespace has drifted, enqueueing", "namespace", ns.Name, "drift", kinds)
+
+		// The NamespaceReconciler's workqueue serializes the reconciliation with the watch events.
+		select {
+		case d.Events <- event.GenericEvent{Object: ns}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		driftEnqueuedTotal.Inc()
+
+		report.Enqueued++
+		enqueued[ns.Name] = true
+	}
+
+	d.enqueued = enqueued
+
+	report.LastCheckTime = metav1.Now()
+
+	driftLastCheckDifferences.WithLabelValues(DriftMissing).Set(float64(report.Missing))
+	driftLastCheckDifferences.WithLabelValues(DriftOutdated).Set(float64(report.Outdated))
+	driftLastCheckDifferences.WithLabelValues(DriftStale).Set(float64(report.Stale))
+	driftLastCheckTimestamp.Set(float64(report.LastCheckTime.Unix()))
+
+	logger.Info("Drift check finished",
+		"checked", report.CheckedNamespaces,
+		"missing", report.Missing,
+		"outdated", report.Outdated,
+		"stale", report.Stale,
+		"enqueued", report.Enqueued,
+		"repaired", report.Repaired,
+		"drifted_namespaces", report.DriftedNamespaces)
+
+	return d.setReport(ctx, report)
+}
+
+// checkNamespace returns the kinds of the differences between the desired and the actual
+// NetworkAttachmentDefinitions in a namespace. The namespaces the NamespaceReconciler does not
+// manage, e.g. with an unknown mesh or an invalid name, and the NetworkAttachmentDefinitions
+// not managed by the operator instance are not checked.
+func (d *DriftDetector) checkNamespace(ctx context.Context, logger logr.Logger, ns *corev1.Namespace,
+	cfg settings.Settings, netAttaches []*netattachv1.NetworkAttachmentDefinition,
+	cniConfigs map[string]*CNIPluginConf) []string {
+	mesh, requiredName, ok := requiredNetAttachName(ns, cfg)
+	if !ok {
+		return nil
+	}
+
+	var (
+		required *netattachv1.NetworkAttachmentDefinition
+		kinds    []string
+	)
+
+	for _, netAttach := range netAttaches {
+		// The NetworkAttachmentDefinitions being deleted are handled by the finalizer.
+		if !netAttach.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		if netAttach.Name == requiredName {
+			required = netAttach
+
+			continue
+		}
+
+		if isManagedNetAttach(netAttach, d.Reconciler.OperatorInstance) {
+			kinds = append(kinds, DriftStale)
+		}
+	}
+
+	if requiredName == "" {
+		return kinds
+	}
+
+	if required == nil {
+		return append(kinds, DriftMissing)
+	}
+
+	if !isManagedNetAttach(required, d.Reconciler.OperatorInstance) {
+		return kinds
+	}
+
+	meshCNIConfig, ok := cniConfigs[mesh.LinkerdNamespace]
+	if !ok {
+		var err error
+
+		meshCNIConfig, err = loadMeshCNIConfig(ctx, d.Reconciler.CNIConfigSource, d.Reconciler.CNIDiscovery, mesh)
+		if err != nil {
+			logger.Error(err, "can not load Linkerd CNI configuration, outdated NetworkAttachmentDefinitions are not checked",
+				"mesh", mesh.LinkerdNamespace)
+		}
+
+		cniConfigs[mesh.LinkerdNamespace] = meshCNIConfig
+	}
+
+	if meshCNIConfig == nil {
+		return kinds
+	}
+
+	// The NamespaceReconciler reports the invalid overrides.
+	cniConfig, err := renderCNIConfig(meshCNIConfig, ns, cfg)
+	if err != nil {
+		return kinds
+	}
+
+	// The NamespaceReconciler reports the invalid chain and the unsupported CNI version.
+	netAttachConfig, _, err := renderNetAttachConfig(cniConfig, cfg)
+	if err != nil {
+		return kinds
+	}
+
+	diff, err := netAttachDiff(required, d.Reconciler.OperatorInstance, d.Reconciler.InUseProtection,
+		netAttachConfig)
+	if err != nil {
+		logger.Error(err, "can not render NetworkAttachmentDefinition", "namespace", ns.Name)
+
+		return kinds
+	}
+
+	if len(diff) != 0 {
+		logger.V(debugLogLevel).Info("NetworkAttachmentDefinition is outdated", "namespace", ns.Name, "diff", diff)
+
+		kinds = append(kinds, DriftOutdated)
+	}
+
+	return kinds
+}
+
+// setReport stores the drift report in the LinkerdMultusConfig status, if the LinkerdMultusConfig exists.
+func (d *DriftDetector) setReport(ctx context.Context, report *multusv1alpha1.DriftReport) error {
+	config, err := d.Reconciler.Settings.GetConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("can not get LinkerdMultusConfig: %w", err)
+	}
+
+	if config == nil {
+		return nil
+	}
+
+	patch := client.MergeFrom(config.DeepCopy())
+	config.Status.Drift = report
+
+	if err := d.Status().Patch(ctx, config, patch); err != nil {
+		return fmt.Errorf("can not patch LinkerdMultusConfig drift status: %w", err)
+	}
+
+	return nil
+}
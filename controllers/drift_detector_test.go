@@ -0,0 +1,281 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	netattachv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	multusv1alpha1 "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/api/v1alpha1"
+	k8s "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
+	"github.com/ErmakovDmitriy/linkerd-multus-attach-operator/settings"
+)
+
+var testDriftSettings = settings.Settings{
+	CNINamespace:      "linkerd-cni",
+	CNIKubeconfigPath: "/etc/cni/net.d/ZZZ-linkerd-cni-kubeconfig",
+	LinkerdNamespace:  "linkerd",
+}
+
+// newTestNamespaceReconciler returns a NamespaceReconciler which reads the objects and the Linkerd CNI
+// configuration ConfigMap with a fake client and records the Events with a fake recorder.
+func newTestNamespaceReconciler(t *testing.T, objects ...runtime.Object) *NamespaceReconciler {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+
+	for _, addToScheme := range []func(*runtime.Scheme) error{
+		clientgoscheme.AddToScheme, netattachv1.AddToScheme, multusv1alpha1.AddToScheme,
+	} {
+		if err := addToScheme(scheme); err != nil {
+			t.Fatalf("AddToScheme() error = %v", err)
+		}
+	}
+
+	linkerdConfig := newTestCNIPluginConf()
+	linkerdConfig.CNIVersion = "0.4.0"
+
+	raw, err := json.Marshal(linkerdConfig)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	objects = append(objects, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testDriftSettings.CNINamespace, Name: k8s.LinkerdCNIConfigMapName},
+		Data:       map[string]string{k8s.LinkerdCNIConfigMapKey: string(raw)},
+	})
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build()
+
+	return &NamespaceReconciler{
+		Client: c,
+		Scheme: scheme,
+		Settings: &settings.Loader{
+			Client:     c,
+			ConfigName: multusv1alpha1.LinkerdMultusConfigNameDefault,
+			Defaults:   testDriftSettings,
+		},
+		OperatorInstance: testOperatorInstance,
+		Recorder:         record.NewFakeRecorder(100),
+		CNIConfigSource:  &ConfigMapCNIConfigSource{Client: c, Name: k8s.LinkerdCNIConfigMapName, Key: k8s.LinkerdCNIConfigMapKey},
+	}
+}
+
+// newTestDriftDetector returns a DriftDetector of the test NamespaceReconciler, see newTestNamespaceReconciler.
+func newTestDriftDetector(t *testing.T, objects ...runtime.Object) (*DriftDetector, chan event.GenericEvent) {
+	t.Helper()
+
+	reconciler := newTestNamespaceReconciler(t, objects...)
+	events := make(chan event.GenericEvent, 10)
+
+	return &DriftDetector{Client: reconciler.Client, Reconciler: reconciler, Events: events}, events
+}
+
+// newDriftTestNamespace returns the "app" namespace, requested has the Multus attachment enabled.
+func newDriftTestNamespace(requested bool) *corev1.Namespace {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}}
+
+	if requested {
+		ns.Annotations = map[string]string{k8s.MultusAttachAnnotation: k8s.MultusAttachEnabled}
+	}
+
+	return ns
+}
+
+// desiredDriftTestNetAttach returns the NetworkAttachmentDefinition the NamespaceReconciler applies to the namespace.
+func desiredDriftTestNetAttach(t *testing.T, d *DriftDetector, ns *corev1.Namespace) *netattachv1.NetworkAttachmentDefinition {
+	t.Helper()
+
+	mesh := testDriftSettings.GetMeshes()[0]
+
+	meshConfig, err := loadMeshCNIConfig(context.Background(), d.Reconciler.CNIConfigSource, nil, &mesh)
+	if err != nil {
+		t.Fatalf("loadMeshCNIConfig() error = %v", err)
+	}
+
+	cniConfig, err := renderCNIConfig(meshConfig, ns, testDriftSettings)
+	if err != nil {
+		t.Fatalf("renderCNIConfig() error = %v", err)
+	}
+
+	config, _, err := renderNetAttachConfig(cniConfig, testDriftSettings)
+	if err != nil {
+		t.Fatalf("renderNetAttachConfig() error = %v", err)
+	}
+
+	return newMultusNetworkAttachDefinition(types.NamespacedName{Namespace: ns.Name, Name: mesh.NetworkAttachmentDefinitionName},
+		testOperatorInstance, false, config)
+}
+
+func TestDriftDetectorCheckNamespace(t *testing.T) {
+	withName := func(netAttach *netattachv1.NetworkAttachmentDefinition, name string) *netattachv1.NetworkAttachmentDefinition {
+		netAttach.Name = name
+
+		return netAttach
+	}
+
+	withLabels := func(netAttach *netattachv1.NetworkAttachmentDefinition,
+		labels map[string]string) *netattachv1.NetworkAttachmentDefinition {
+		netAttach.Labels = labels
+
+		return netAttach
+	}
+
+	tests := []struct {
+		name        string
+		requested   bool
+		netAttaches func(desired *netattachv1.NetworkAttachmentDefinition) []*netattachv1.NetworkAttachmentDefinition
+		expected    []string
+	}{
+		{
+			name: "not requested",
+		},
+		{
+			name:      "up to date",
+			requested: true,
+			netAttaches: func(desired *netattachv1.NetworkAttachmentDefinition) []*netattachv1.NetworkAttachmentDefinition {
+				return []*netattachv1.NetworkAttachmentDefinition{desired}
+			},
+		},
+		{
+			name:      "missing",
+			requested: true,
+			expected:  []string{DriftMissing},
+		},
+		{
+			name:      "deleted NetworkAttachmentDefinition is missing",
+			requested: true,
+			netAttaches: func(desired *netattachv1.NetworkAttachmentDefinition) []*netattachv1.NetworkAttachmentDefinition {
+				now := metav1.Now()
+				desired.DeletionTimestamp = &now
+
+				return []*netattachv1.NetworkAttachmentDefinition{desired}
+			},
+			expected: []string{DriftMissing},
+		},
+		{
+			name:      "outdated",
+			requested: true,
+			netAttaches: func(desired *netattachv1.NetworkAttachmentDefinition) []*netattachv1.NetworkAttachmentDefinition {
+				return []*netattachv1.NetworkAttachmentDefinition{newTestNetAttach(testLinkerdCNIConfig)}
+			},
+			expected: []string{DriftOutdated},
+		},
+		{
+			name:      "not managed NetworkAttachmentDefinition is not outdated",
+			requested: true,
+			netAttaches: func(desired *netattachv1.NetworkAttachmentDefinition) []*netattachv1.NetworkAttachmentDefinition {
+				return []*netattachv1.NetworkAttachmentDefinition{withLabels(newTestNetAttach(testLinkerdCNIConfig), nil)}
+			},
+		},
+		{
+			name: "stale",
+			netAttaches: func(desired *netattachv1.NetworkAttachmentDefinition) []*netattachv1.NetworkAttachmentDefinition {
+				return []*netattachv1.NetworkAttachmentDefinition{desired}
+			},
+			expected: []string{DriftStale},
+		},
+		{
+			name:      "stale NetworkAttachmentDefinition besides the required one",
+			requested: true,
+			netAttaches: func(desired *netattachv1.NetworkAttachmentDefinition) []*netattachv1.NetworkAttachmentDefinition {
+				return []*netattachv1.NetworkAttachmentDefinition{withName(desired.DeepCopy(), "linkerd-cni-old"), desired}
+			},
+			expected: []string{DriftStale},
+		},
+		{
+			name: "NetworkAttachmentDefinition of other operator instance is not stale",
+			netAttaches: func(desired *netattachv1.NetworkAttachmentDefinition) []*netattachv1.NetworkAttachmentDefinition {
+				return []*netattachv1.NetworkAttachmentDefinition{withLabels(desired, managedLabels("other"))}
+			},
+		},
+		{
+			name:      "stale and outdated",
+			requested: true,
+			netAttaches: func(desired *netattachv1.NetworkAttachmentDefinition) []*netattachv1.NetworkAttachmentDefinition {
+				return []*netattachv1.NetworkAttachmentDefinition{
+					withName(desired, "linkerd-cni-old"), newTestNetAttach(testLinkerdCNIConfig),
+				}
+			},
+			expected: []string{DriftStale, DriftOutdated},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, _ := newTestDriftDetector(t)
+			ns := newDriftTestNamespace(tt.requested)
+
+			var netAttaches []*netattachv1.NetworkAttachmentDefinition
+			if tt.netAttaches != nil {
+				netAttaches = tt.netAttaches(desiredDriftTestNetAttach(t, d, newDriftTestNamespace(true)))
+			}
+
+			got := d.checkNamespace(context.Background(), log.Log, ns, testDriftSettings, netAttaches,
+				make(map[string]*CNIPluginConf))
+
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("checkNamespace() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDriftDetectorCheckRepaired(t *testing.T) {
+	ctx := context.Background()
+	ns := newDriftTestNamespace(true)
+	config := &multusv1alpha1.LinkerdMultusConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: multusv1alpha1.LinkerdMultusConfigNameDefault},
+	}
+
+	d, events := newTestDriftDetector(t, ns, config)
+
+	check := func() *multusv1alpha1.DriftReport {
+		t.Helper()
+
+		if err := d.check(ctx, log.Log); err != nil {
+			t.Fatalf("check() error = %v", err)
+		}
+
+		if err := d.Get(ctx, client.ObjectKeyFromObject(config), config); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+
+		return config.Status.Drift
+	}
+
+	report := check()
+	if report.Missing != 1 || report.Enqueued != 1 || report.Repaired != 0 || len(events) != 1 {
+		t.Fatalf("check() = %+v, %d events, want 1 missing and enqueued, 0 repaired, 1 event", report, len(events))
+	}
+
+	<-events
+
+	// The NamespaceReconciler repairs the namespace.
+	if err := d.Create(ctx, desiredDriftTestNetAttach(t, d, ns)); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	report = check()
+	if report.Missing != 0 || report.Enqueued != 0 || report.Repaired != 1 || len(events) != 0 {
+		t.Errorf("check() = %+v, %d events, want 0 missing and enqueued, 1 repaired, no events", report, len(events))
+	}
+
+	// The namespace is counted as repaired once.
+	report = check()
+	if report.Repaired != 0 {
+		t.Errorf("check() repaired = %d, want 0", report.Repaired)
+	}
+}
@@ -3,26 +3,99 @@ package controllers
 import (
 	k8s "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
-// getEventFilter returns a static filter which omits events for all objects which do not have
-// a hard-coded MultusNetworkAttachmentDefinitionName as its name.
-func getEventFilter() predicate.Predicate {
+// getEventFilter returns a filter which omits events for all objects which are not labelled
+// as managed by the given operator instance.
+func getEventFilter(operatorInstance string) predicate.Predicate {
 	return predicate.Funcs{
 		CreateFunc: func(ce event.CreateEvent) bool {
-			return ce.Object.GetName() == k8s.MultusNetworkAttachmentDefinitionName
+			return isManagedNetAttach(ce.Object, operatorInstance)
 		},
 		UpdateFunc: func(ue event.UpdateEvent) bool {
-			return (ue.ObjectNew.GetName() == k8s.MultusNetworkAttachmentDefinitionName ||
-				ue.ObjectOld.GetName() == k8s.MultusNetworkAttachmentDefinitionName)
+			// The old object is checked to catch the ownership labels removal.
+			return (isManagedNetAttach(ue.ObjectNew, operatorInstance) ||
+				isManagedNetAttach(ue.ObjectOld, operatorInstance))
 		},
 		DeleteFunc: func(de event.DeleteEvent) bool {
-			return de.Object.GetName() == k8s.MultusNetworkAttachmentDefinitionName
+			return isManagedNetAttach(de.Object, operatorInstance)
 		},
 		GenericFunc: func(ge event.GenericEvent) bool {
-			return ge.Object.GetName() == k8s.MultusNetworkAttachmentDefinitionName
+			return isManagedNetAttach(ge.Object, operatorInstance)
 		},
 	}
 }
+
+// getObjectNameEventFilter returns a filter which passes only events for the objects with the given name,
+// e.g. the Linkerd CNI ConfigMaps. The namespace is checked by the event handler
+// as it may be changed by the LinkerdMultusConfig.
+func getObjectNameEventFilter(name string) predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(o client.Object) bool {
+		return o.GetName() == name
+	})
+}
+
+// getLinkerdCNIDaemonSetEventFilter returns a filter which passes only events for the Linkerd CNI DaemonSets.
+// Status updates are ignored.
+func getLinkerdCNIDaemonSetEventFilter() predicate.Predicate {
+	return predicate.And(
+		predicate.NewPredicateFuncs(func(o client.Object) bool {
+			return o.GetLabels()[k8s.LinkerdCNIDaemonSetLabel] == k8s.LinkerdCNIDaemonSetLabelValue
+		}),
+		predicate.GenerationChangedPredicate{},
+	)
+}
+
+// getLinkerdCNIResourceEventFilter returns a filter which passes only events for the resources
+// of a Linkerd CNI installation, e.g. a discovered Linkerd CNI ConfigMap with a not configured name.
+func getLinkerdCNIResourceEventFilter() predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(o client.Object) bool {
+		return o.GetLabels()[k8s.LinkerdCNIResourceLabel] == "true"
+	})
+}
+
+// getSettingsEventFilter returns a filter which passes only events for the LinkerdMultusConfig
+// with the given name. Status updates are ignored.
+func getSettingsEventFilter(configName string) predicate.Predicate {
+	return predicate.And(
+		predicate.NewPredicateFuncs(func(o client.Object) bool {
+			return o.GetName() == configName
+		}),
+		predicate.GenerationChangedPredicate{},
+	)
+}
+
+// getNamespaceEventFilter returns a filter which omits Namespace update events
+// caused only by the controller's status annotation change.
+func getNamespaceEventFilter() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(ue event.UpdateEvent) bool {
+			return !isOnlyStatusAnnotationChanged(ue.ObjectOld, ue.ObjectNew)
+		},
+	}
+}
+
+func isOnlyStatusAnnotationChanged(oldObj, newObj client.Object) bool {
+	oldNs, okOld := oldObj.(*corev1.Namespace)
+	newNs, okNew := newObj.(*corev1.Namespace)
+
+	if !okOld || !okNew ||
+		oldNs.Annotations[k8s.NamespaceStatusAnnotation] == newNs.Annotations[k8s.NamespaceStatusAnnotation] {
+		return false
+	}
+
+	oldNs, newNs = oldNs.DeepCopy(), newNs.DeepCopy()
+
+	for _, ns := range []*corev1.Namespace{oldNs, newNs} {
+		delete(ns.Annotations, k8s.NamespaceStatusAnnotation)
+		ns.ResourceVersion = ""
+		ns.ManagedFields = nil
+	}
+
+	return equality.Semantic.DeepEqual(oldNs, newNs)
+}
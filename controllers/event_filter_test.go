@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"testing"
+
+	netattachv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	k8s "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
+)
+
+func TestGetEventFilter(t *testing.T) {
+	withLabels := func(labels map[string]string) *netattachv1.NetworkAttachmentDefinition {
+		netAttach := newTestNetAttach(testLinkerdCNIConfig)
+		netAttach.Labels = labels
+
+		return netAttach
+	}
+
+	var (
+		managed    = withLabels(managedLabels(testOperatorInstance))
+		unlabelled = withLabels(nil)
+		foreign    = withLabels(managedLabels("other"))
+	)
+
+	tests := []struct {
+		name     string
+		object   client.Object
+		expected bool
+	}{
+		{"managed", managed, true},
+		{"unlabelled", unlabelled, false},
+		{"other operator instance", foreign, false},
+	}
+
+	filter := getEventFilter(testOperatorInstance)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filter.Create(event.CreateEvent{Object: tt.object}); got != tt.expected {
+				t.Errorf("Create() = %v, want %v", got, tt.expected)
+			}
+
+			if got := filter.Update(event.UpdateEvent{ObjectOld: tt.object, ObjectNew: tt.object}); got != tt.expected {
+				t.Errorf("Update() = %v, want %v", got, tt.expected)
+			}
+
+			if got := filter.Delete(event.DeleteEvent{Object: tt.object}); got != tt.expected {
+				t.Errorf("Delete() = %v, want %v", got, tt.expected)
+			}
+
+			if got := filter.Generic(event.GenericEvent{Object: tt.object}); got != tt.expected {
+				t.Errorf("Generic() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+
+	t.Run("ownership labels removal", func(t *testing.T) {
+		if !filter.Update(event.UpdateEvent{ObjectOld: managed, ObjectNew: unlabelled}) {
+			t.Error("Update() = false, want true")
+		}
+	})
+
+	t.Run("adopted by other operator instance", func(t *testing.T) {
+		if filter.Update(event.UpdateEvent{ObjectOld: unlabelled, ObjectNew: foreign}) {
+			t.Error("Update() = true, want false")
+		}
+	})
+}
+
+func TestGetNamespaceEventFilter(t *testing.T) {
+	newNamespace := func(status string, labels map[string]string) *corev1.Namespace {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "app",
+			Labels:      labels,
+			Annotations: map[string]string{k8s.MultusAttachAnnotation: k8s.MultusAttachEnabled},
+		}}
+
+		if status != "" {
+			ns.Annotations[k8s.NamespaceStatusAnnotation] = status
+		}
+
+		return ns
+	}
+
+	tests := []struct {
+		name     string
+		oldObj   client.Object
+		newObj   client.Object
+		expected bool
+	}{
+		{"only status annotation is changed", newNamespace(`{"result":"Created"}`, nil),
+			newNamespace(`{"result":"InSync"}`, nil), false},
+		{"status annotation is added", newNamespace("", nil), newNamespace(`{"result":"Created"}`, nil), false},
+		{"status annotation and labels are changed", newNamespace(`{"result":"Created"}`, nil),
+			newNamespace(`{"result":"InSync"}`, map[string]string{"team": "a"}), true},
+		{"status annotation is not changed", newNamespace(`{"result":"Created"}`, nil),
+			newNamespace(`{"result":"Created"}`, map[string]string{"team": "a"}), true},
+		{"not a Namespace", newTestNetAttach(testLinkerdCNIConfig), newTestNetAttach(`{}`), true},
+	}
+
+	filter := getNamespaceEventFilter()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filter.Update(event.UpdateEvent{ObjectOld: tt.oldObj, ObjectNew: tt.newObj}); got != tt.expected {
+				t.Errorf("Update() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
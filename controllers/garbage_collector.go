@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/go-logr/logr"
+	netattachv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+)
+
+// ReasonGarbageCollected is the reason of the Events about NetworkAttachmentDefinitions
+// removed by the GarbageCollector.
+const ReasonGarbageCollected = "GarbageCollected"
+
+// GarbageCollector removes, once after the leader election, the NetworkAttachmentDefinitions
+// managed by the operator instance in the namespaces which do not require them anymore,
+// e.g. the namespaces which were un-annotated while the operator was down.
+type GarbageCollector struct {
+	client.Client
+	// Reconciler provides the settings, the operator instance and the Event recorder.
+	Reconciler *NamespaceReconciler
+	// DryRun only reports the NetworkAttachmentDefinitions which would be removed.
+	DryRun bool
+}
+
+// Start runs the garbage collection once. Errors are logged and do not stop the manager.
+func (g *GarbageCollector) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("garbage-collector").WithValues("dry_run", g.DryRun)
+
+	logger.Info("Collecting orphaned Multus NetworkAttachmentDefinitions")
+
+	deleted, err := g.collect(ctx, logger)
+	if err != nil {
+		logger.Error(err, "can not collect orphaned Multus NetworkAttachmentDefinitions")
+
+		return nil
+	}
+
+	logger.Info("Orphaned Multus NetworkAttachmentDefinitions collected", "count", deleted)
+
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable,
+// only the leader deletes NetworkAttachmentDefinitions.
+func (g *GarbageCollector) NeedLeaderElection() bool {
+	return true
+}
+
+// collect deletes the orphaned NetworkAttachmentDefinitions and returns their number.
+func (g *GarbageCollector) collect(ctx context.Context, logger logr.Logger) (int, error) {
+	cfg, err := g.Reconciler.Settings.Load(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("can not load operator settings: %w", err)
+	}
+
+	// Audit mode never deletes anything.
+	dryRun := g.DryRun || cfg.IsAudit()
+
+	var netAttaches = &netattachv1.NetworkAttachmentDefinitionList{}
+
+	if err := g.List(ctx, netAttaches, client.MatchingLabels(managedLabels(g.Reconciler.OperatorInstance))); err != nil {
+		return 0, fmt.Errorf("can not list Multus NetworkAttachmentDefinitions: %w", err)
+	}
+
+	var deleted int
+
+	for i := range netAttaches.Items {
+		var (
+			netAttach = &netAttaches.Items[i]
+			ns        = &corev1.Namespace{}
+		)
+
+		if err := g.Get(ctx, client.ObjectKey{Name: netAttach.Namespace}, ns); err != nil {
+			// The NetworkAttachmentDefinition is deleted with its namespace.
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			return deleted, fmt.Errorf("can not get Namespace %s: %w", netAttach.Namespace, err)
+		}
+
+		if ns.Status.Phase == corev1.NamespaceTerminating {
+			continue
+		}
+
+		_, requiredName, ok := requiredNetAttachName(ns, cfg)
+		if !ok || netAttach.Name == requiredName {
+			continue
+		}
+
+		netAttachRef := client.ObjectKeyFromObject(netAttach).String()
+
+		if dryRun {
+			logger.Info("Orphaned Multus NetworkAttachmentDefinition would be deleted", "name", netAttachRef)
+			g.Reconciler.Recorder.Event(ns, corev1.EventTypeNormal, ReasonGarbageCollected,
+				"Dry run: orphaned NetworkAttachmentDefinition "+netAttachRef+" would be deleted")
+
+			deleted++
+
+			continue
+		}
+
+		if err := deleteMultusNetAttach(ctx, g.Client, netAttach, NetAttachReasonGarbageCollected); err != nil {
+			return deleted, fmt.Errorf("can not delete Multus NetworkAttachmentDefinition %s: %w", netAttachRef, err)
+		}
+
+		logger.Info("Orphaned Multus NetworkAttachmentDefinition deleted", "name", netAttachRef)
+		g.Reconciler.Recorder.Event(ns, corev1.EventTypeNormal, ReasonGarbageCollected,
+			"Deleted orphaned NetworkAttachmentDefinition "+netAttachRef)
+
+		deleted++
+	}
+
+	return deleted, nil
+}
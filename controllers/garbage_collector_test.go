@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	netattachv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	k8s "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
+)
+
+// newGCTestObjects returns the namespaces and the NetworkAttachmentDefinitions of the garbage collection tests.
+// Only the web/linkerd-cni and app/linkerd-cni-old NetworkAttachmentDefinitions are orphaned.
+func newGCTestObjects() []runtime.Object {
+	netAttach := func(namespace, name string, labels map[string]string) *netattachv1.NetworkAttachmentDefinition {
+		return &netattachv1.NetworkAttachmentDefinition{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels},
+		}
+	}
+
+	requested := map[string]string{k8s.MultusAttachAnnotation: k8s.MultusAttachEnabled}
+
+	return []runtime.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app", Annotations: requested}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "web"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "db"}, Status: corev1.NamespaceStatus{
+			Phase: corev1.NamespaceTerminating,
+		}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "invalid", Annotations: map[string]string{
+			k8s.MultusAttachAnnotation:                    k8s.MultusAttachEnabled,
+			k8s.NetworkAttachmentDefinitionNameAnnotation: "Linkerd_CNI",
+		}}},
+		// Required.
+		netAttach("app", k8s.MultusNetworkAttachmentDefinitionName, managedLabels(testOperatorInstance)),
+		// Orphaned.
+		netAttach("app", "linkerd-cni-old", managedLabels(testOperatorInstance)),
+		netAttach("web", k8s.MultusNetworkAttachmentDefinitionName, managedLabels(testOperatorInstance)),
+		// Not managed by the operator instance.
+		netAttach("web", "macvlan", nil),
+		netAttach("web", "linkerd-cni-other", managedLabels("other")),
+		// The namespace is being deleted.
+		netAttach("db", k8s.MultusNetworkAttachmentDefinitionName, managedLabels(testOperatorInstance)),
+		// The required name can not be determined.
+		netAttach("invalid", k8s.MultusNetworkAttachmentDefinitionName, managedLabels(testOperatorInstance)),
+		// The namespace does not exist.
+		netAttach("gone", k8s.MultusNetworkAttachmentDefinitionName, managedLabels(testOperatorInstance)),
+	}
+}
+
+// listGCTestNetAttaches returns the "{{ namespace }}/{{ name }}" of all the NetworkAttachmentDefinitions.
+func listGCTestNetAttaches(t *testing.T, c client.Client) []string {
+	t.Helper()
+
+	var netAttaches = &netattachv1.NetworkAttachmentDefinitionList{}
+
+	if err := c.List(context.Background(), netAttaches); err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	var refs = make([]string, 0, len(netAttaches.Items))
+
+	for i := range netAttaches.Items {
+		refs = append(refs, client.ObjectKeyFromObject(&netAttaches.Items[i]).String())
+	}
+
+	sort.Strings(refs)
+
+	return refs
+}
+
+// drainEvents returns the Events recorded by the fake recorder.
+func drainEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+
+	for {
+		select {
+		case e := <-recorder.Events:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func TestGarbageCollectorCollect(t *testing.T) {
+	tests := []struct {
+		name                string
+		dryRun              bool
+		expectedNetAttaches []string
+		expectedEvent       string
+	}{
+		{
+			name: "orphaned NetworkAttachmentDefinitions are deleted",
+			expectedNetAttaches: []string{
+				"app/linkerd-cni", "db/linkerd-cni", "gone/linkerd-cni", "invalid/linkerd-cni",
+				"web/linkerd-cni-other", "web/macvlan",
+			},
+			expectedEvent: "Deleted orphaned NetworkAttachmentDefinition",
+		},
+		{
+			name:   "dry run",
+			dryRun: true,
+			expectedNetAttaches: []string{
+				"app/linkerd-cni", "app/linkerd-cni-old", "db/linkerd-cni", "gone/linkerd-cni", "invalid/linkerd-cni",
+				"web/linkerd-cni", "web/linkerd-cni-other", "web/macvlan",
+			},
+			expectedEvent: "Dry run: orphaned NetworkAttachmentDefinition",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reconciler := newTestNamespaceReconciler(t, newGCTestObjects()...)
+			gc := &GarbageCollector{Client: reconciler.Client, Reconciler: reconciler, DryRun: tt.dryRun}
+
+			deleted, err := gc.collect(context.Background(), log.Log)
+			if err != nil {
+				t.Fatalf("collect() error = %v", err)
+			}
+
+			if deleted != 2 {
+				t.Errorf("collect() = %d, want 2", deleted)
+			}
+
+			if got := listGCTestNetAttaches(t, gc.Client); !reflect.DeepEqual(got, tt.expectedNetAttaches) {
+				t.Errorf("NetworkAttachmentDefinitions = %q, want %q", got, tt.expectedNetAttaches)
+			}
+
+			events := drainEvents(reconciler.Recorder.(*record.FakeRecorder))
+			if len(events) != 2 {
+				t.Fatalf("Events = %q, want 2", events)
+			}
+
+			for _, e := range events {
+				if !strings.Contains(e, ReasonGarbageCollected) || !strings.Contains(e, tt.expectedEvent) {
+					t.Errorf("Event = %q, want %s %q", e, ReasonGarbageCollected, tt.expectedEvent)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2022 ErmakovDmitriy.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	multusv1alpha1 "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/api/v1alpha1"
+	"github.com/ErmakovDmitriy/linkerd-multus-attach-operator/settings"
+)
+
+// LinkerdMultusConfigReconciler maintains the status of the LinkerdMultusConfig
+// resource which configures the operator.
+type LinkerdMultusConfigReconciler struct {
+	client.Client
+	// Settings loads the operator configuration.
+	Settings *settings.Loader
+	// CNIConfigSource provides the Linkerd CNI configuration of the meshes.
+	CNIConfigSource CNIConfigSource
+	// CNIDiscovery finds the Linkerd CNI installations of the meshes, nil disables the discovery.
+	CNIDiscovery *CNIDiscovery
+}
+
+//+kubebuilder:rbac:groups=multus.linkerd.io,resources=linkerdmultusconfigs,verbs=get;list;watch
+//+kubebuilder:rbac:groups=multus.linkerd.io,resources=linkerdmultusconfigs/status,verbs=get;update;patch
+
+// Reconcile updates the LinkerdMultusConfig status with the observed generation,
+// the Linkerd CNI configuration source and state and the number of managed namespaces.
+func (r *LinkerdMultusConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("linkerdmultusconfig", req.Name)
+
+	logger.V(debugLogLevel).Info("Reconcile event")
+
+	config, err := r.Settings.GetConfig(ctx)
+	if err != nil {
+		logger.Error(err, "can not get LinkerdMultusConfig")
+
+		return ctrl.Result{}, err
+	}
+
+	if config == nil {
+		logger.V(debugLogLevel).Info("LinkerdMultusConfig was deleted, no action needed")
+
+		return ctrl.Result{}, nil
+	}
+
+	cfg := r.Settings.Defaults.Merge(&config.Spec)
+
+	var namespaces = &corev1.NamespaceList{}
+
+	if err := r.List(ctx, namespaces); err != nil {
+		logger.Error(err, "can not list Namespaces")
+
+		return ctrl.Result{}, fmt.Errorf("can not list Namespaces: %w", err)
+	}
+
+	var managedNamespaces int32
+
+	for i := range namespaces.Items {
+		if isNetAttachRequired(&namespaces.Items[i], cfg) && cfg.MeshForNamespace(&namespaces.Items[i]) != nil {
+			managedNamespaces++
+		}
+	}
+
+	var (
+		meshes  = cfg.GetMeshes()
+		sources = make([]string, 0, len(meshes))
+	)
+
+	for i := range meshes {
+		meshSource, cniNamespace, err := resolveCNIConfigSource(ctx, r.CNIConfigSource, r.CNIDiscovery, &meshes[i])
+		if err != nil {
+			meshSource, cniNamespace = r.CNIConfigSource, meshes[i].CNINamespace
+		}
+
+		sources = append(sources, meshSource.Describe(cniNamespace))
+	}
+
+	status := config.Status.DeepCopy()
+	status.ObservedGeneration = config.Generation
+	status.CNIConfigSource = strings.Join(sources, ", ")
+	status.ManagedNamespaces = managedNamespaces
+
+	var readyCondition = metav1.Condition{
+		Type:               multusv1alpha1.ConditionTypeReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "ConfigLoaded",
+		Message:            "Linkerd CNI configuration is loaded",
+		ObservedGeneration: config.Generation,
+	}
+
+	var downgrades []string
+
+	// The first mesh with invalid configuration makes the configuration not ready.
+	for i := range meshes {
+		cniConfig, err := loadMeshCNIConfig(ctx, r.CNIConfigSource, r.CNIDiscovery, &meshes[i])
+		if err == nil {
+			_, _, err = renderNetAttachConfig(cniConfig, cfg)
+		}
+
+		if err == nil {
+			if cniVersion, isDowngraded, _ := negotiateCNIVersion(cniConfig.CNIVersion, cfg); isDowngraded {
+				downgrades = append(downgrades, fmt.Sprintf("mesh %s: cniVersion %s is downgraded to %s",
+					meshes[i].LinkerdNamespace, cniConfig.CNIVersion, cniVersion))
+			}
+
+			continue
+		}
+
+		readyCondition.Status = metav1.ConditionFalse
+		readyCondition.Message = fmt.Sprintf("mesh %s: %s", meshes[i].LinkerdNamespace, err.Error())
+
+		switch {
+		case errors.Is(err, ErrCNIConfigNotFound):
+			readyCondition.Reason = ResultConfigMissing
+		case errors.Is(err, ErrCNIChained):
+			readyCondition.Reason = ResultCNIChained
+		case errors.Is(err, ErrCNIConfigNamespaceNotCached):
+			readyCondition.Reason = ResultNamespaceNotCached
+		case errors.Is(err, ErrUnsupportedCNIVersion):
+			readyCondition.Reason = ResultUnsupportedCNIVersion
+		case errors.Is(err, ErrInvalidCNIChain):
+			readyCondition.Reason = ResultInvalidChain
+		case isCNIConfigInvalid(err):
+			readyCondition.Reason = ResultConfigInvalid
+		default:
+			readyCondition.Reason = ResultFailed
+		}
+
+		break
+	}
+
+	if readyCondition.Status == metav1.ConditionTrue && len(downgrades) != 0 {
+		readyCondition.Message += ", " + strings.Join(downgrades, ", ")
+	}
+
+	meta.SetStatusCondition(&status.Conditions, readyCondition)
+
+	if equality.Semantic.DeepEqual(&config.Status, status) {
+		logger.V(debugLogLevel).Info("LinkerdMultusConfig status is up to date")
+
+		return ctrl.Result{}, nil
+	}
+
+	config.Status = *status
+
+	if err := r.Status().Update(ctx, config); err != nil {
+		logger.Error(err, "can not update LinkerdMultusConfig status")
+
+		return ctrl.Result{}, fmt.Errorf("can not update LinkerdMultusConfig status: %w", err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// enqueueConfig returns a reconciliation request for the LinkerdMultusConfig.
+// Any Namespace or Linkerd CNI configuration change may change the LinkerdMultusConfig status.
+func (r *LinkerdMultusConfigReconciler) enqueueConfig(client.Object) []reconcile.Request {
+	return []reconcile.Request{
+		{
+			NamespacedName: types.NamespacedName{
+				Name: r.Settings.ConfigName,
+			},
+		},
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *LinkerdMultusConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	blder := ctrl.NewControllerManagedBy(mgr).
+		For(&multusv1alpha1.LinkerdMultusConfig{}, builder.WithPredicates(getSettingsEventFilter(r.Settings.ConfigName))).
+		Watches(
+			&source.Kind{Type: &corev1.Namespace{}},
+			handler.EnqueueRequestsFromMapFunc(r.enqueueConfig),
+			builder.WithPredicates(getNamespaceEventFilter(), predicate.Or(
+				predicate.AnnotationChangedPredicate{}, predicate.LabelChangedPredicate{})),
+		)
+
+	if r.CNIDiscovery != nil {
+		blder = blder.Watches(
+			&source.Kind{Type: &appsv1.DaemonSet{}},
+			handler.EnqueueRequestsFromMapFunc(r.enqueueConfig),
+			builder.WithPredicates(getLinkerdCNIDaemonSetEventFilter()),
+		)
+	}
+
+	return r.CNIConfigSource.Watch(blder, func(string) []reconcile.Request {
+		return r.enqueueConfig(nil)
+	}).Complete(r)
+}
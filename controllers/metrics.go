@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const metricsNamespace = "linkerd_multus"
+
+// NetworkAttachmentDefinition operations.
+const (
+	netAttachOperationCreate = "create"
+	netAttachOperationUpdate = "update"
+	netAttachOperationDelete = "delete"
+)
+
+// Reasons of the NetworkAttachmentDefinition operations.
+const (
+	// NetAttachReasonRequired - NetworkAttachmentDefinition is required in a namespace.
+	NetAttachReasonRequired = "required"
+	// NetAttachReasonConfigChanged - NetworkAttachmentDefinition differs from the Linkerd CNI configuration.
+	NetAttachReasonConfigChanged = "config_changed"
+	// NetAttachReasonAdopted - NetworkAttachmentDefinition not managed by the operator is adopted.
+	NetAttachReasonAdopted = "adopted"
+	// NetAttachReasonNotRequired - NetworkAttachmentDefinition is not required in a namespace.
+	NetAttachReasonNotRequired = "not_required"
+	// NetAttachReasonGarbageCollected - NetworkAttachmentDefinition is removed by the startup garbage collection.
+	NetAttachReasonGarbageCollected = "garbage_collected"
+)
+
+// Reasons of the Linkerd CNI configuration load errors.
+const (
+	cniConfigErrorConfigMapNotFound = "configmap_not_found"
+	cniConfigErrorSecretNotFound    = "secret_not_found"
+	cniConfigErrorFileNotFound      = "file_not_found"
+	cniConfigErrorKeyNotFound       = "key_not_found"
+	cniConfigErrorUnmarshal         = "unmarshal"
+	cniConfigErrorInvalid           = "invalid"
+)
+
+var (
+	netAttachOperationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "nad",
+		Name:      "operations_total",
+		Help:      "Number of successful NetworkAttachmentDefinition operations by operation and reason.",
+	}, []string{"operation", "reason"})
+
+	netAttachOperationFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "nad",
+		Name:      "operation_failures_total",
+		Help:      "Number of failed NetworkAttachmentDefinition operations by operation and Kubernetes API error reason.",
+	}, []string{"operation", "reason"})
+
+	netAttachAuditOperationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "nad",
+		Name:      "audit_operations_total",
+		Help:      "Number of NetworkAttachmentDefinition operations skipped in Audit mode by operation and reason.",
+	}, []string{"operation", "reason"})
+
+	cniConfigLoadErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "cni_config",
+		Name:      "load_errors_total",
+		Help:      "Number of Linkerd CNI configuration load errors by reason: configmap_not_found, secret_not_found, file_not_found, key_not_found, unmarshal or invalid.",
+	}, []string{"reason"})
+
+	driftChecksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "drift",
+		Name:      "checks_total",
+		Help:      "Number of periodic drift checks by result: success or error.",
+	}, []string{"result"})
+
+	driftDetectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "drift",
+		Name:      "detected_total",
+		Help:      "Number of NetworkAttachmentDefinition differences found by the drift checks by kind: missing, outdated or stale.",
+	}, []string{"kind"})
+
+	driftEnqueuedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "drift",
+		Name:      "enqueued_total",
+		Help:      "Number of drifted namespaces enqueued for the reconciliation by the drift checks.",
+	})
+
+	driftRepairedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "drift",
+		Name:      "repaired_total",
+		Help:      "Number of enqueued namespaces found no longer drifted by the next drift check.",
+	})
+
+	driftLastCheckDifferences = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "drift",
+		Name:      "last_check_differences",
+		Help:      "Number of NetworkAttachmentDefinition differences found by the last drift check by kind.",
+	}, []string{"kind"})
+
+	driftLastCheckTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "drift",
+		Name:      "last_check_timestamp_seconds",
+		Help:      "Unix time of the last successful drift check.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		netAttachOperationsTotal,
+		netAttachOperationFailuresTotal,
+		netAttachAuditOperationsTotal,
+		cniConfigLoadErrorsTotal,
+		driftChecksTotal,
+		driftDetectedTotal,
+		driftEnqueuedTotal,
+		driftRepairedTotal,
+		driftLastCheckDifferences,
+		driftLastCheckTimestamp,
+	)
+}
+
+// recordNetAttachOperation counts a NetworkAttachmentDefinition operation, the failed operations
+// are counted by the Kubernetes API error reason.
+func recordNetAttachOperation(operation, reason string, err error) {
+	if err == nil {
+		netAttachOperationsTotal.WithLabelValues(operation, reason).Inc()
+
+		return
+	}
+
+	errReason := string(apierrors.ReasonForError(err))
+	if errReason == "" {
+		errReason = "Unknown"
+	}
+
+	netAttachOperationFailuresTotal.WithLabelValues(operation, errReason).Inc()
+}
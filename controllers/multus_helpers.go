@@ -2,7 +2,6 @@ package controllers
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 
 	k8s "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
@@ -10,13 +9,35 @@ import (
 	netattachv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-func newMultusNetworkAttachDefinition(multusRef client.ObjectKey,
-	config *CNIPluginConf) (*netattachv1.NetworkAttachmentDefinition, error) {
-	var multusNetAttach = &netattachv1.NetworkAttachmentDefinition{
+// NetAttachFieldManager is the server-side apply field manager of the NetworkAttachmentDefinitions.
+const NetAttachFieldManager = k8s.ManagedByLabelValue
+
+// managedLabels returns labels which mark a NetworkAttachmentDefinition as managed
+// by the given operator instance.
+func managedLabels(operatorInstance string) map[string]string {
+	return map[string]string{
+		k8s.ManagedByLabel:        k8s.ManagedByLabelValue,
+		k8s.OperatorInstanceLabel: operatorInstance,
+	}
+}
+
+// isManagedNetAttach checks if a NetworkAttachmentDefinition is managed by the given operator instance.
+func isManagedNetAttach(multus client.Object, operatorInstance string) bool {
+	labels := multus.GetLabels()
+
+	return labels[k8s.ManagedByLabel] == k8s.ManagedByLabelValue &&
+		labels[k8s.OperatorInstanceLabel] == operatorInstance
+}
+
+// newMultusNetworkAttachDefinition returns the NetworkAttachmentDefinition the operator instance applies.
+// inUseProtection adds the NetAttachFinalizer which holds the deletion while Pods use the NetworkAttachmentDefinition.
+func newMultusNetworkAttachDefinition(multusRef client.ObjectKey, operatorInstance string, inUseProtection bool,
+	config string) *netattachv1.NetworkAttachmentDefinition {
+	netAttach := &netattachv1.NetworkAttachmentDefinition{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       k8s.MultusNetworkAttachmentDefinitionKind,
 			APIVersion: k8s.MultusNetworkAttachmentDefinitionAPIVersion,
@@ -24,46 +45,82 @@ func newMultusNetworkAttachDefinition(multusRef client.ObjectKey,
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      multusRef.Name,
 			Namespace: multusRef.Namespace,
+			Labels:    managedLabels(operatorInstance),
+		},
+		Spec: netattachv1.NetworkAttachmentDefinitionSpec{
+			Config: config,
 		},
 	}
 
-	cfg, err := json.Marshal(config)
-	if err != nil {
-		return nil, fmt.Errorf("NetworkAttachmentDefinition configuration JSON Marshal error: %w", err)
+	// The server-side apply removes the finalizer the operator has set, when the protection is disabled.
+	if inUseProtection {
+		controllerutil.AddFinalizer(netAttach, k8s.NetAttachFinalizer)
 	}
 
-	multusNetAttach.Spec.Config = string(cfg)
-
-	return multusNetAttach, nil
+	return netAttach
 }
 
 func deleteMultusNetAttach(ctx context.Context, k8s client.Client,
-	multus *netattachv1.NetworkAttachmentDefinition) error {
+	multus *netattachv1.NetworkAttachmentDefinition, reason string) error {
 	if err := k8s.Delete(ctx, multus); err != nil {
 		// Already deleted, nothing to do.
 		if errors.IsNotFound(err) {
 			return nil
 		}
 
+		recordNetAttachOperation(netAttachOperationDelete, reason, err)
+
 		return err
 	}
 
+	recordNetAttachOperation(netAttachOperationDelete, reason, nil)
+
 	return nil
 }
 
-func createMultusNetAttach(ctx context.Context, k8s client.Client,
-	multusRef client.ObjectKey, linkerdCNINamespace, cniKubeconfigPath string) error {
-	cniConfig, err := getCNINetworkConfig(ctx, k8s, linkerdCNINamespace, cniKubeconfigPath)
-	if err != nil {
-		return err
+// applyMultusNetAttach server-side applies a NetworkAttachmentDefinition with NetAttachFieldManager,
+// so the operator owns only the fields it sets and the fields set by others are kept.
+// forceOwnership takes over the fields managed by other field managers instead of failing with a conflict.
+func applyMultusNetAttach(ctx context.Context, k8s client.Client,
+	netAttach *netattachv1.NetworkAttachmentDefinition, forceOwnership bool) error {
+	var opts = []client.PatchOption{client.FieldOwner(NetAttachFieldManager)}
+
+	if forceOwnership {
+		opts = append(opts, client.ForceOwnership)
 	}
 
-	netAttach, err := newMultusNetworkAttachDefinition(multusRef, cniConfig)
-	if err != nil {
+	if err := k8s.Patch(ctx, netAttach, client.Apply, opts...); err != nil {
+		if errors.IsConflict(err) {
+			return fmt.Errorf("NetworkAttachmentDefinition fields are managed by another field manager, "+
+				"force the ownership to take them over: %w", err)
+		}
+
 		return err
 	}
 
-	if err := k8s.Create(ctx, netAttach); err != nil {
+	return nil
+}
+
+// isAppliedByFieldManager checks if NetAttachFieldManager has server-side applied the object.
+func isAppliedByFieldManager(obj client.Object) bool {
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Manager == NetAttachFieldManager && entry.Operation == metav1.ManagedFieldsOperationApply {
+			return true
+		}
+	}
+
+	return false
+}
+
+func createMultusNetAttach(ctx context.Context, k8s client.Client,
+	multusRef client.ObjectKey, operatorInstance string, inUseProtection bool, config string, forceOwnership bool) error {
+	netAttach := newMultusNetworkAttachDefinition(multusRef, operatorInstance, inUseProtection, config)
+
+	err := applyMultusNetAttach(ctx, k8s, netAttach, forceOwnership)
+
+	recordNetAttachOperation(netAttachOperationCreate, NetAttachReasonRequired, err)
+
+	if err != nil {
 		return fmt.Errorf("can not create Multus NetworkAttachmentDefinition %s/%s: %w",
 			netAttach.ObjectMeta.Namespace, netAttach.ObjectMeta.Name, err)
 	}
@@ -71,34 +128,82 @@ func createMultusNetAttach(ctx context.Context, k8s client.Client,
 	return nil
 }
 
-func updateMultusNetAttach(ctx context.Context, k8s client.Client, logger logr.Logger,
-	currentMultus *netattachv1.NetworkAttachmentDefinition, linkerdCNINamespace, cniKubeconfigPath string) error {
-	cniConfig, err := getCNINetworkConfig(ctx, k8s, linkerdCNINamespace, cniKubeconfigPath)
+// netAttachDiff returns the differences between a NetworkAttachmentDefinition and the one the operator
+// instance renders with the given configuration. The configurations are compared semantically.
+// Empty result means that the NetworkAttachmentDefinition is in sync.
+func netAttachDiff(currentMultus *netattachv1.NetworkAttachmentDefinition, operatorInstance string,
+	inUseProtection bool, config string) ([]string, error) {
+	var diff []string
+
+	if !isManagedNetAttach(currentMultus, operatorInstance) {
+		diff = append(diff, "labels: not managed by operator instance "+operatorInstance)
+	}
+
+	switch hasFinalizer := controllerutil.ContainsFinalizer(currentMultus, k8s.NetAttachFinalizer); {
+	case inUseProtection && !hasFinalizer:
+		diff = append(diff, "finalizers: "+k8s.NetAttachFinalizer+" is missing")
+	case !inUseProtection && hasFinalizer:
+		diff = append(diff, "finalizers: "+k8s.NetAttachFinalizer+" is not required")
+	}
+
+	requiredConfig, err := parseNetAttachConfig(config)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	requiredMultus, err := newMultusNetworkAttachDefinition(
-		types.NamespacedName{
-			Namespace: currentMultus.Namespace,
-			Name:      currentMultus.Name,
-		}, cniConfig)
+	currentConfig, err := parseNetAttachConfig(currentMultus.Spec.Config)
 	if err != nil {
-		return err
+		return append(diff, "config: "+err.Error()), nil
+	}
+
+	return append(diff, diffNetAttachConfig(currentConfig, requiredConfig)...), nil
+}
+
+// updateMultusNetAttach updates a NetworkAttachmentDefinition to match the given configuration.
+// Returns the differences which were fixed, empty if the NetworkAttachmentDefinition was not changed.
+func updateMultusNetAttach(ctx context.Context, k8s client.Client, logger logr.Logger,
+	currentMultus *netattachv1.NetworkAttachmentDefinition, operatorInstance string, inUseProtection bool, config string,
+	forceOwnership bool) ([]string, error) {
+	diff, err := netAttachDiff(currentMultus, operatorInstance, inUseProtection, config)
+	if err != nil {
+		return nil, err
 	}
 
-	if currentMultus.Spec == requiredMultus.Spec {
+	if len(diff) == 0 {
 		logger.V(debugLogLevel).Info("Current and required states are equal, nothing to update")
 
-		return nil
+		return nil, nil
+	}
+
+	logger.Info("Multus NetworkAttachmentDefinition differs from the required one", "diff", diff)
+
+	requiredMultus := newMultusNetworkAttachDefinition(client.ObjectKeyFromObject(currentMultus), operatorInstance,
+		inUseProtection, config)
+
+	reason := NetAttachReasonConfigChanged
+	if !isManagedNetAttach(currentMultus, operatorInstance) {
+		reason = NetAttachReasonAdopted
+	}
+
+	// The NetworkAttachmentDefinitions of the operator instance which were never applied were written
+	// by an operator version which did not use server-side apply, their fields are owned by its Update
+	// field manager, so the first apply takes them over.
+	if isManagedNetAttach(currentMultus, operatorInstance) && !isAppliedByFieldManager(currentMultus) {
+		logger.Info("Multus NetworkAttachmentDefinition was not applied by the operator, taking over its fields",
+			"field_manager", NetAttachFieldManager)
+
+		forceOwnership = true
 	}
 
-	currentMultus.Spec = requiredMultus.Spec
+	// The labels and the fields set by others are kept by the server-side apply.
+	err = applyMultusNetAttach(ctx, k8s, requiredMultus, forceOwnership)
 
-	if err := k8s.Update(ctx, currentMultus); err != nil {
-		return fmt.Errorf("can not update Multus NetworkAttachmentDefinition %s/%s: %w",
+	recordNetAttachOperation(netAttachOperationUpdate, reason, err)
+
+	if err != nil {
+		return nil, fmt.Errorf("can not update Multus NetworkAttachmentDefinition %s/%s: %w",
 			currentMultus.ObjectMeta.Namespace, currentMultus.ObjectMeta.Name, err)
 	}
 
-	return nil
+	return diff, nil
 }
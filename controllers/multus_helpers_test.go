@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	netattachv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestUpdateMultusNetAttachOwnership(t *testing.T) {
+	withManagers := func(netAttach *netattachv1.NetworkAttachmentDefinition,
+		entries ...metav1.ManagedFieldsEntry) *netattachv1.NetworkAttachmentDefinition {
+		netAttach.ManagedFields = entries
+
+		return netAttach
+	}
+
+	var (
+		applied = metav1.ManagedFieldsEntry{Manager: NetAttachFieldManager, Operation: metav1.ManagedFieldsOperationApply}
+		updated = metav1.ManagedFieldsEntry{Manager: "manager", Operation: metav1.ManagedFieldsOperationUpdate}
+		// The API server names the fields of an object without the managed fields so on the first apply.
+		beforeFirstApply = metav1.ManagedFieldsEntry{Manager: "before-first-apply", Operation: metav1.ManagedFieldsOperationUpdate}
+	)
+
+	unlabelled := newTestNetAttach(testLinkerdCNIConfig)
+	unlabelled.Labels = nil
+
+	tests := []struct {
+		name           string
+		netAttach      *netattachv1.NetworkAttachmentDefinition
+		forceOwnership bool
+		expected       bool
+	}{
+		{"written by Update", withManagers(newTestNetAttach(testLinkerdCNIConfig), updated), false, true},
+		{"written before the first apply", withManagers(newTestNetAttach(testLinkerdCNIConfig), beforeFirstApply),
+			false, true},
+		{"applied", withManagers(newTestNetAttach(testLinkerdCNIConfig), updated, applied), false, false},
+		{"applied with forced ownership", withManagers(newTestNetAttach(testLinkerdCNIConfig), applied), true, true},
+		{"adopted", withManagers(unlabelled, updated), false, false},
+		{"adopted with forced ownership", withManagers(unlabelled.DeepCopy(), updated), true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &applyRecordingClient{}
+
+			diff, err := updateMultusNetAttach(context.Background(), c, log.Log, tt.netAttach, testOperatorInstance, false,
+				`{"cniVersion":"0.3.1","name":"linkerd-cni","type":"linkerd-cni"}`, tt.forceOwnership)
+			if err != nil {
+				t.Fatalf("updateMultusNetAttach() error = %v", err)
+			}
+
+			if len(diff) == 0 || len(c.forced) != 1 {
+				t.Fatalf("updateMultusNetAttach() = %q, %d applies, want a diff and 1 apply", diff, len(c.forced))
+			}
+
+			if c.forced[0] != tt.expected {
+				t.Errorf("ForceOwnership = %v, want %v", c.forced[0], tt.expected)
+			}
+		})
+	}
+}
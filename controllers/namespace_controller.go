@@ -23,21 +23,29 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	multusv1alpha1 "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/api/v1alpha1"
 	k8s "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
+	"github.com/ErmakovDmitriy/linkerd-multus-attach-operator/settings"
+	"github.com/go-logr/logr"
 	netattachv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
 )
 
@@ -47,16 +55,42 @@ const debugLogLevel = 1
 // NetworkAttachmentDefinitions.
 type NamespaceReconciler struct {
 	client.Client
-	Scheme                       *runtime.Scheme
-	LinkerdControlPlaneNamespace string
-	LinkerdCNINamespace          string
-	LinkerdCNIKubeconfigPath     string
+	Scheme *runtime.Scheme
+	// Settings loads the operator configuration.
+	Settings *settings.Loader
+	// OperatorInstance is the value of the OperatorInstanceLabel label which marks
+	// NetworkAttachmentDefinitions managed by this instance of the operator.
+	OperatorInstance string
+	// AdoptionPolicy defines how to handle existing NetworkAttachmentDefinitions
+	// which are not labelled as managed by the operator.
+	AdoptionPolicy AdoptionPolicy
+	// ForceOwnership takes over the NetworkAttachmentDefinition fields managed by other
+	// server-side apply field managers instead of failing with a conflict.
+	ForceOwnership bool
+	// InUseProtection adds the NetAttachFinalizer to the managed NetworkAttachmentDefinitions, so their deletion
+	// is held while Pods use them. Disabling it removes the finalizer from the NetworkAttachmentDefinitions.
+	InUseProtection bool
+	// APIReader lists Pods directly from the API server to check if a NetworkAttachmentDefinition
+	// being deleted is in use, so the Pods are not cached.
+	APIReader client.Reader
+	// Recorder reports reconciliation results as Namespace Events.
+	Recorder record.EventRecorder
+	// CNIConfigSource provides the Linkerd CNI configuration of the meshes.
+	CNIConfigSource CNIConfigSource
+	// CNIDiscovery finds the Linkerd CNI installations of the meshes, nil disables the discovery.
+	CNIDiscovery *CNIDiscovery
+	// DriftEvents enqueues the Namespaces found drifted by the DriftDetector, nil if the drift detection is disabled.
+	DriftEvents <-chan event.GenericEvent
 }
 
 //+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=namespaces/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups="",resources=namespaces/finalizers,verbs=update
 //+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=multus.linkerd.io,resources=linkerdmultusconfigs,verbs=get;list;watch
+//+kubebuilder:rbac:groups=multus.linkerd.io,resources=linkerdmultusconfigs/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=k8s.cni.cncf.io,resources=network-attachment-definitions,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
@@ -77,7 +111,7 @@ func (r *NamespaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	var ns = &corev1.Namespace{}
 
 	if err := r.Get(ctx, req.NamespacedName, ns); err != nil {
-		if errors.IsNotFound(err) {
+		if apierrors.IsNotFound(err) {
 			logger.V(debugLogLevel).Info("Namespace was deleted, no action needed")
 
 			return ctrl.Result{}, nil
@@ -88,6 +122,27 @@ func (r *NamespaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, fmt.Errorf("can not get Namespace: %w", err)
 	}
 
+	// NetworkAttachmentDefinitions being deleted are released first, also in a Namespace which is terminating.
+	isDeletionBlocked, err := r.finalizeNetAttaches(ctx, logger, ns)
+	if err != nil {
+		logger.Error(err, "can not finalize Multus NetworkAttachmentDefinitions")
+
+		return ctrl.Result{}, err
+	}
+
+	result, err := r.reconcileNetAttach(ctx, logger, ns)
+
+	// Pods are not watched, so the blocked deletion is checked periodically.
+	if err == nil && isDeletionBlocked && result.IsZero() {
+		result.RequeueAfter = netAttachInUseRequeueAfter
+	}
+
+	return result, err
+}
+
+// reconcileNetAttach creates, updates or deletes the Multus NetworkAttachmentDefinitions in a namespace.
+func (r *NamespaceReconciler) reconcileNetAttach(ctx context.Context, logger logr.Logger,
+	ns *corev1.Namespace) (ctrl.Result, error) {
 	// Stop processing for a Namespace which is terminating.
 	if ns.Status.Phase == corev1.NamespaceTerminating {
 		logger.V(debugLogLevel).Info("Namespace is Terminating, not action needed")
@@ -95,92 +150,473 @@ func (r *NamespaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, nil
 	}
 
-	// Check if Multus NetworkAttachmentDefinition must be in the namespace.
-	var isMultusRequired bool
+	cfg, err := r.Settings.Load(ctx)
+	if err != nil {
+		logger.Error(err, "can not load operator settings")
 
-	if req.Name == r.LinkerdControlPlaneNamespace {
-		logger.V(debugLogLevel).Info("Controller namespace must always have NetworkAttachmentDefinition")
+		return ctrl.Result{}, err
+	}
 
-		isMultusRequired = true
-	} else {
-		// Check if Multus is requested in the Namespace.
-		isMultusRequired = (ns.Annotations[k8s.MultusAttachAnnotation] == k8s.MultusAttachEnabled)
+	// Check if Multus NetworkAttachmentDefinition must be in the namespace and which mesh it belongs to.
+	var (
+		mesh             = cfg.MeshForNamespace(ns)
+		isMultusRequired = isNetAttachRequired(ns, cfg)
+	)
+
+	if isMultusRequired && mesh == nil {
+		message := "Namespace references unknown Linkerd control plane " +
+			settings.ControlPlaneNamespaceReference(ns.Labels, ns.Annotations)
+		logger.Info(message)
+		r.report(ctx, logger, ns, corev1.EventTypeWarning, ResultUnknownMesh, message, "")
+
+		isMultusRequired = false
+	}
+
+	logger.V(debugLogLevel).Info("Checked if Multus NetworkAttachmentDefinition is required", "is_required", isMultusRequired)
+
+	// Managed NetworkAttachmentDefinitions which are not required anymore are deleted,
+	// e.g. when the namespace has moved to another mesh.
+	var requiredName string
+
+	if isMultusRequired {
+		requiredName, err = mesh.NetworkAttachmentDefinitionNameFor(ns)
+		if err != nil {
+			// Nothing is changed until the annotation is fixed.
+			logger.Error(err, "Namespace NetworkAttachmentDefinition name annotation is not valid")
+			r.report(ctx, logger, ns, corev1.EventTypeWarning, ResultInvalidName, err.Error(), "")
+
+			return ctrl.Result{}, nil
+		}
+	}
+
+	deletedCount, err := r.deleteStaleNetAttaches(ctx, logger, ns, requiredName, cfg.IsAudit())
+	if err != nil {
+		logger.Error(err, "can not delete Multus NetworkAttachmentDefinition")
+		r.report(ctx, logger, ns, corev1.EventTypeWarning, ResultFailed, err.Error(), "")
+
+		return ctrl.Result{}, err
+	}
+
+	if !isMultusRequired {
+		if deletedCount == 0 && mesh != nil {
+			logger.V(debugLogLevel).Info("Multus NetworkAttachmentDefinition is not required, do nothing")
+			r.report(ctx, logger, ns, "", ResultNotRequired, "", "")
+		}
+
+		return ctrl.Result{}, nil
 	}
 
 	var (
 		multusNetAttach = &netattachv1.NetworkAttachmentDefinition{}
 		multusRef       = types.NamespacedName{
-			Namespace: req.Name,
-			Name:      k8s.MultusNetworkAttachmentDefinitionName,
+			Namespace: ns.Name,
+			Name:      requiredName,
 		}
+		isNetAttachFound = true
 	)
 
-	logger = logger.WithValues("multusRef", multusRef.String())
-
-	logger.V(debugLogLevel).Info("Checked if Multus NetworkAttachmentDefinition is required", "is_required", isMultusRequired)
+	logger = logger.WithValues("multusRef", multusRef.String(), "mesh", mesh.LinkerdNamespace)
 
 	if err := r.Get(ctx, multusRef, multusNetAttach); err != nil {
 		// Errors except NotFound are treated as errors.
-		if !errors.IsNotFound(err) {
+		if !apierrors.IsNotFound(err) {
 			logger.Error(err, "Can not get Multus NetworkAttachmentDefinition")
+			r.report(ctx, logger, ns, corev1.EventTypeWarning, ResultFailed, err.Error(), "")
 
 			return ctrl.Result{}, fmt.Errorf("can not get Multus NetworkAttachmentDefinition: %w", err)
 		}
 
-		// Here we have a state "NetworkAttachmentDefinition is not found in the namespace".
+		isNetAttachFound = false
+	}
+
+	// The NetworkAttachmentDefinition is recreated when its deletion completes.
+	if isNetAttachFound && !multusNetAttach.DeletionTimestamp.IsZero() {
+		logger.Info("Multus NetworkAttachmentDefinition is being deleted, it is recreated after the deletion")
 
-		// No Multus in the namespace and required - create new.
-		if isMultusRequired {
-			logger.V(debugLogLevel).Info("Multus NetworkAttachmentDefinition is not in the Namespace and required, creating")
+		return ctrl.Result{}, nil
+	}
 
-			if err := createMultusNetAttach(ctx, r.Client, multusRef,
-				r.LinkerdCNINamespace, r.LinkerdCNIKubeconfigPath); err != nil {
-				logger.Error(err, "can not create Multus NetworkAttachmentDefinition")
+	// Someone else's NetworkAttachmentDefinition is handled according to the adoption policy.
+	if isNetAttachFound && !isManagedNetAttach(multusNetAttach, r.OperatorInstance) {
+		// Other operator instances' NetworkAttachmentDefinitions are never adopted.
+		if multusNetAttach.Labels[k8s.ManagedByLabel] == k8s.ManagedByLabelValue {
+			logger.Info("Multus NetworkAttachmentDefinition is managed by another operator instance, ignoring it",
+				"instance", multusNetAttach.Labels[k8s.OperatorInstanceLabel])
+			r.report(ctx, logger, ns, "", ResultIgnored,
+				"NetworkAttachmentDefinition is managed by operator instance "+multusNetAttach.Labels[k8s.OperatorInstanceLabel], "")
 
-				return ctrl.Result{}, err
+			return ctrl.Result{}, nil
+		}
+
+		switch r.AdoptionPolicy {
+		case AdoptionPolicyAdopt:
+			logger.Info("Multus NetworkAttachmentDefinition is not managed by the operator, adopting")
+
+			// In Audit mode the adoption is reported as the would-be update.
+			if !cfg.IsAudit() {
+				r.Recorder.Event(ns, corev1.EventTypeNormal, ResultAdopted,
+					"Adopting NetworkAttachmentDefinition "+multusRef.String())
 			}
+		case AdoptionPolicyFail:
+			err := fmt.Errorf("%w: %s", ErrNetAttachNotManaged, multusRef.String())
+			logger.Error(err, "Multus NetworkAttachmentDefinition is required but can not be adopted")
+			r.report(ctx, logger, ns, corev1.EventTypeWarning, ResultNotManaged, err.Error(), "")
+
+			return ctrl.Result{}, err
+		default:
+			logger.Info("Multus NetworkAttachmentDefinition is not managed by the operator, ignoring it",
+				"adoption_policy", r.AdoptionPolicy)
+			r.report(ctx, logger, ns, "", ResultIgnored, "NetworkAttachmentDefinition is not managed by the operator", "")
 
 			return ctrl.Result{}, nil
 		}
+	}
 
-		// Multus NetworkAttachmentDefinition is not found and not required.
-		logger.V(debugLogLevel).Info("Multus NetworkAttachmentDefinition is not found in the Namespace and not required, do nothing")
+	// Here the NetworkAttachmentDefinition is required, so the mesh's Linkerd CNI configuration is necessary.
+	meshCNIConfig, err := loadMeshCNIConfig(ctx, r.CNIConfigSource, r.CNIDiscovery, mesh)
+	if err != nil {
+		return r.handleCNIConfigError(ctx, logger, ns, err)
+	}
+
+	// Nothing is changed until the namespace overrides are fixed, the namespace watch retriggers the reconciliation.
+	cniConfig, err := renderCNIConfig(meshCNIConfig, ns, cfg)
+	if err != nil {
+		logger.Error(err, "Namespace Linkerd CNI configuration overrides are not valid")
+		r.report(ctx, logger, ns, corev1.EventTypeWarning, ResultInvalidOverride, err.Error(), "")
 
 		return ctrl.Result{}, nil
 	}
 
-	// Here we have the state "NetworkAttachmentDefinition is found in the namespace".
+	// The LinkerdMultusConfig watch retriggers the reconciliation when the chain or the CNI version is fixed.
+	netAttachConfig, isDowngraded, err := renderNetAttachConfig(cniConfig, cfg)
+	if err != nil {
+		result := ResultInvalidChain
+		if errors.Is(err, ErrUnsupportedCNIVersion) {
+			result = ResultUnsupportedCNIVersion
+		}
 
-	// We have Multus in the Namespace, decide what to do with it.
-	if !isMultusRequired {
-		logger.V(debugLogLevel).Info("Multus NetworkAttachmentDefinition is in the Namespace and not required, deleting")
+		logger.Error(err, "NetworkAttachmentDefinition configuration can not be rendered")
+		r.report(ctx, logger, ns, corev1.EventTypeWarning, result, err.Error(), "")
 
-		if err := deleteMultusNetAttach(ctx, r.Client, multusNetAttach); err != nil {
-			logger.Error(err, "can not delete Multus NetworkAttachmentDefinition")
+		return ctrl.Result{}, nil
+	}
+
+	// The downgrade is reported with an Event only when the NetworkAttachmentDefinition is changed,
+	// so the Events are not repeated on every reconciliation.
+	var downgradeMessage string
+
+	if isDowngraded {
+		cniVersion, _, _ := negotiateCNIVersion(cniConfig.CNIVersion, cfg)
+		downgradeMessage = fmt.Sprintf("Linkerd CNI configuration cniVersion %s is not supported, "+
+			"NetworkAttachmentDefinition %s is rendered with cniVersion %s", cniConfig.CNIVersion, multusRef.String(), cniVersion)
+
+		logger.Info("Linkerd CNI configuration cniVersion is downgraded",
+			"source_version", cniConfig.CNIVersion, "target_version", cniVersion)
+	}
+
+	configHash := cniConfigHash(netAttachConfig)
+
+	if cfg.IsAudit() {
+		return r.audit(ctx, logger, ns, multusRef, isNetAttachFound, multusNetAttach, netAttachConfig, configHash)
+	}
+
+	// No Multus in the namespace and required - create new.
+	if !isNetAttachFound {
+		logger.V(debugLogLevel).Info("Multus NetworkAttachmentDefinition is not in the Namespace and required, creating")
+
+		if err := createMultusNetAttach(ctx, r.Client, multusRef, r.OperatorInstance, r.InUseProtection,
+			netAttachConfig, r.ForceOwnership); err != nil {
+			logger.Error(err, "can not create Multus NetworkAttachmentDefinition")
+			r.report(ctx, logger, ns, corev1.EventTypeWarning, ResultFailed, err.Error(), configHash)
 
 			return ctrl.Result{}, err
 		}
 
+		r.report(ctx, logger, ns, corev1.EventTypeNormal, ResultCreated,
+			"Created NetworkAttachmentDefinition "+multusRef.String(), configHash)
+		r.reportDowngrade(ns, downgradeMessage)
+
 		return ctrl.Result{}, nil
 	}
 
 	// Update multus if necessary.
 	logger.V(debugLogLevel).Info("Multus NetworkAttachmentDefinition is in the Namespace and required, patch if changed")
 
-	if err := updateMultusNetAttach(ctx, r.Client, logger,
-		multusNetAttach, r.LinkerdCNINamespace, r.LinkerdCNIKubeconfigPath); err != nil {
+	diff, err := updateMultusNetAttach(ctx, r.Client, logger, multusNetAttach, r.OperatorInstance,
+		r.InUseProtection, netAttachConfig, r.ForceOwnership)
+	if err != nil {
 		logger.Error(err, "can not update Multus NetworkAttachmentDefinition")
+		r.report(ctx, logger, ns, corev1.EventTypeWarning, ResultFailed, err.Error(), configHash)
 
 		return ctrl.Result{}, err
 	}
 
+	if len(diff) != 0 {
+		r.report(ctx, logger, ns, corev1.EventTypeNormal, ResultUpdated,
+			"Updated NetworkAttachmentDefinition "+multusRef.String()+": "+strings.Join(diff, ", "), configHash)
+		r.reportDowngrade(ns, downgradeMessage)
+	} else {
+		r.report(ctx, logger, ns, "", ResultInSync, "", configHash)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// reportDowngrade records a Warning Event about the CNI version downgrade, if the message is not empty.
+func (r *NamespaceReconciler) reportDowngrade(ns *corev1.Namespace, downgradeMessage string) {
+	if downgradeMessage != "" {
+		r.Recorder.Event(ns, corev1.EventTypeWarning, ReasonCNIVersionDowngraded, downgradeMessage)
+	}
+}
+
+// audit reports the NetworkAttachmentDefinition change the reconciliation would make in Audit mode.
+func (r *NamespaceReconciler) audit(ctx context.Context, logger logr.Logger, ns *corev1.Namespace,
+	multusRef types.NamespacedName, isNetAttachFound bool, multusNetAttach *netattachv1.NetworkAttachmentDefinition,
+	netAttachConfig string, configHash string) (ctrl.Result, error) {
+	if !isNetAttachFound {
+		logger.Info("Audit mode: Multus NetworkAttachmentDefinition would be created")
+		netAttachAuditOperationsTotal.WithLabelValues(netAttachOperationCreate, NetAttachReasonRequired).Inc()
+		r.report(ctx, logger, ns, corev1.EventTypeNormal, ResultWouldCreate,
+			"Audit mode: NetworkAttachmentDefinition "+multusRef.String()+" would be created", configHash)
+
+		return ctrl.Result{}, nil
+	}
+
+	diff, err := netAttachDiff(multusNetAttach, r.OperatorInstance, r.InUseProtection, netAttachConfig)
+	if err != nil {
+		logger.Error(err, "can not render Multus NetworkAttachmentDefinition")
+
+		return ctrl.Result{}, err
+	}
+
+	if len(diff) == 0 {
+		r.report(ctx, logger, ns, "", ResultInSync, "", configHash)
+
+		return ctrl.Result{}, nil
+	}
+
+	reason := NetAttachReasonConfigChanged
+	if !isManagedNetAttach(multusNetAttach, r.OperatorInstance) {
+		reason = NetAttachReasonAdopted
+	}
+
+	logger.Info("Audit mode: Multus NetworkAttachmentDefinition would be updated", "reason", reason, "diff", diff)
+	netAttachAuditOperationsTotal.WithLabelValues(netAttachOperationUpdate, reason).Inc()
+	r.report(ctx, logger, ns, corev1.EventTypeNormal, ResultWouldUpdate,
+		"Audit mode: NetworkAttachmentDefinition "+multusRef.String()+" would be updated: "+strings.Join(diff, ", "), configHash)
+
 	return ctrl.Result{}, nil
 }
 
+// deleteStaleNetAttaches deletes the NetworkAttachmentDefinitions managed by the operator instance
+// in a namespace except the one with the required name. Empty requiredName means that all
+// the managed NetworkAttachmentDefinitions are deleted. In Audit mode the deletions are only reported.
+// Returns the number of deleted ones.
+func (r *NamespaceReconciler) deleteStaleNetAttaches(ctx context.Context, logger logr.Logger,
+	ns *corev1.Namespace, requiredName string, isAudit bool) (int, error) {
+	var netAttaches = &netattachv1.NetworkAttachmentDefinitionList{}
+
+	if err := r.List(ctx, netAttaches, client.InNamespace(ns.Name),
+		client.MatchingLabels(managedLabels(r.OperatorInstance))); err != nil {
+		return 0, fmt.Errorf("can not list Multus NetworkAttachmentDefinitions: %w", err)
+	}
+
+	var deletedCount int
+
+	for i := range netAttaches.Items {
+		netAttach := &netAttaches.Items[i]
+
+		// Already being deleted, the deletion is completed by finalizeNetAttaches.
+		if netAttach.Name == requiredName || !netAttach.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		if isAudit {
+			logger.Info("Audit mode: Multus NetworkAttachmentDefinition would be deleted", "name", netAttach.Name)
+			netAttachAuditOperationsTotal.WithLabelValues(netAttachOperationDelete, NetAttachReasonNotRequired).Inc()
+
+			deletedCount++
+
+			r.report(ctx, logger, ns, corev1.EventTypeNormal, ResultWouldDelete,
+				"Audit mode: NetworkAttachmentDefinition "+client.ObjectKeyFromObject(netAttach).String()+" would be deleted", "")
+
+			continue
+		}
+
+		logger.V(debugLogLevel).Info("Multus NetworkAttachmentDefinition is in the Namespace and not required, deleting",
+			"name", netAttach.Name)
+
+		if err := deleteMultusNetAttach(ctx, r.Client, netAttach, NetAttachReasonNotRequired); err != nil {
+			return deletedCount, fmt.Errorf("can not delete Multus NetworkAttachmentDefinition %s/%s: %w",
+				netAttach.Namespace, netAttach.Name, err)
+		}
+
+		deletedCount++
+
+		r.report(ctx, logger, ns, corev1.EventTypeNormal, ResultDeleted,
+			"Deleted NetworkAttachmentDefinition "+client.ObjectKeyFromObject(netAttach).String(), "")
+	}
+
+	return deletedCount, nil
+}
+
+// isNetAttachRequired checks if Multus NetworkAttachmentDefinition must be in the namespace.
+func isNetAttachRequired(ns *corev1.Namespace, cfg settings.Settings) bool {
+	// Control plane namespaces must always have NetworkAttachmentDefinition.
+	if cfg.MeshByLinkerdNamespace(ns.Name) != nil {
+		return true
+	}
+
+	// Check if Multus is requested in the Namespace by the annotation or the label.
+	return k8s.MultusAttachValue(ns.Annotations, ns.Labels, cfg.AttachLabel) == k8s.MultusAttachEnabled
+}
+
+// requiredNetAttachName returns the mesh of a namespace and the name of the NetworkAttachmentDefinition
+// which must be in the namespace, the name is empty if no NetworkAttachmentDefinition is required.
+// Returns false, if the required name can not be determined and the namespace must be left untouched.
+func requiredNetAttachName(ns *corev1.Namespace, cfg settings.Settings) (*settings.Mesh, string, bool) {
+	mesh := cfg.MeshForNamespace(ns)
+	if mesh == nil || !isNetAttachRequired(ns, cfg) {
+		return mesh, "", true
+	}
+
+	name, err := mesh.NetworkAttachmentDefinitionNameFor(ns)
+	if err != nil {
+		return mesh, "", false
+	}
+
+	return mesh, name, true
+}
+
+// handleCNIConfigError reports a Linkerd CNI configuration load error.
+// If the Linkerd CNI ConfigMap is not found, the reconciliation is retried with
+// the rate limiter's backoff. The Linkerd CNI ConfigMap watch triggers
+// reconciliation as soon as the ConfigMap is created, so the backoff
+// is only a safety net. Invalid configuration is not retried as the ConfigMap watch
+// triggers reconciliation when the configuration is fixed.
+func (r *NamespaceReconciler) handleCNIConfigError(ctx context.Context, logger logr.Logger,
+	ns *corev1.Namespace, err error) (ctrl.Result, error) {
+	switch {
+	case errors.Is(err, ErrCNIConfigNotFound):
+		logger.Info("Linkerd CNI configuration is not found, waiting for it to appear", "reason", err.Error())
+		r.report(ctx, logger, ns, corev1.EventTypeWarning, ResultConfigMissing, err.Error(), "")
+
+		return ctrl.Result{Requeue: true}, nil
+	case errors.Is(err, ErrCNIChained):
+		// The DaemonSet watch retriggers the reconciliation when Linkerd CNI is reinstalled.
+		logger.Info("Linkerd CNI is chained into the primary CNI configuration, NetworkAttachmentDefinition is not rendered",
+			"reason", err.Error())
+		r.report(ctx, logger, ns, corev1.EventTypeWarning, ResultCNIChained, err.Error(), "")
+
+		return ctrl.Result{}, nil
+	case errors.Is(err, ErrCNIConfigNamespaceNotCached):
+		// The namespaces are fixed on start, the LinkerdMultusConfig watch retriggers the reconciliation.
+		logger.Error(err, "Linkerd CNI configuration is out of the operator's namespaces")
+		r.report(ctx, logger, ns, corev1.EventTypeWarning, ResultNamespaceNotCached, err.Error(), "")
+
+		return ctrl.Result{}, nil
+	case isCNIConfigInvalid(err):
+		logger.Error(err, "Linkerd CNI configuration is invalid")
+		r.report(ctx, logger, ns, corev1.EventTypeWarning, ResultConfigInvalid, err.Error(), "")
+
+		return ctrl.Result{}, nil
+	default:
+		logger.Error(err, "can not load Linkerd CNI configuration")
+		r.report(ctx, logger, ns, corev1.EventTypeWarning, ResultFailed, err.Error(), "")
+
+		return ctrl.Result{}, err
+	}
+}
+
+// namespacesForCNIConfig returns reconciliation requests for all the namespaces
+// which require Multus NetworkAttachmentDefinition. It is used to re-render
+// the NetworkAttachmentDefinitions when the Linkerd CNI configuration changes.
+// Empty cniNamespace means that the configuration of all the meshes changed.
+func (r *NamespaceReconciler) namespacesForCNIConfig(cniNamespace string) []reconcile.Request {
+	var (
+		ctx    = context.Background()
+		logger = log.FromContext(ctx).WithValues("source", r.CNIConfigSource.Describe(cniNamespace))
+	)
+
+	cfg, err := r.Settings.Load(ctx)
+	if err != nil {
+		logger.Error(err, "can not load operator settings")
+
+		return nil
+	}
+
+	// Only the namespaces of the meshes which use the configuration are re-rendered.
+	requests, err := listNamespaceRequests(ctx, r.Client, func(ns *corev1.Namespace) bool {
+		mesh := cfg.MeshForNamespace(ns)
+		if mesh == nil || !isNetAttachRequired(ns, cfg) {
+			return false
+		}
+
+		// The discovered Linkerd CNI namespace of a mesh may differ from the configured one.
+		return cniNamespace == "" || r.CNIDiscovery != nil || mesh.CNINamespace == cniNamespace
+	})
+	if err != nil {
+		logger.Error(err, "can not list Namespaces to re-render Multus NetworkAttachmentDefinitions")
+
+		return nil
+	}
+
+	logger.V(debugLogLevel).Info("Linkerd CNI configuration changed, enqueue Namespaces", "count", len(requests))
+
+	return requests
+}
+
+// namespacesForSettings returns reconciliation requests for all the namespaces.
+// It is used when the LinkerdMultusConfig changes as any namespace may start or stop
+// requiring the NetworkAttachmentDefinition.
+func (r *NamespaceReconciler) namespacesForSettings(o client.Object) []reconcile.Request {
+	var (
+		ctx    = context.Background()
+		logger = log.FromContext(ctx).WithValues("linkerdmultusconfig", o.GetName())
+	)
+
+	requests, err := listNamespaceRequests(ctx, r.Client, func(*corev1.Namespace) bool { return true })
+	if err != nil {
+		logger.Error(err, "can not list Namespaces to apply LinkerdMultusConfig")
+
+		return nil
+	}
+
+	logger.V(debugLogLevel).Info("LinkerdMultusConfig changed, enqueue Namespaces", "count", len(requests))
+
+	return requests
+}
+
+// listNamespaceRequests returns reconciliation requests for the namespaces selected by the filter.
+func listNamespaceRequests(ctx context.Context, c client.Client,
+	filter func(ns *corev1.Namespace) bool) ([]reconcile.Request, error) {
+	var namespaces = &corev1.NamespaceList{}
+
+	if err := c.List(ctx, namespaces); err != nil {
+		return nil, err
+	}
+
+	var requests = make([]reconcile.Request, 0, len(namespaces.Items))
+
+	for i := range namespaces.Items {
+		if !filter(&namespaces.Items[i]) {
+			continue
+		}
+
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name: namespaces.Items[i].Name,
+			},
+		})
+	}
+
+	return requests, nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *NamespaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&corev1.Namespace{}).
+	blder := ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Namespace{}, builder.WithPredicates(getNamespaceEventFilter())).
 		Watches(
 			&source.Kind{Type: &netattachv1.NetworkAttachmentDefinition{}},
 			handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
@@ -192,7 +628,27 @@ func (r *NamespaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 					},
 				}
 			}),
-			builder.WithPredicates(getEventFilter()),
+			builder.WithPredicates(getEventFilter(r.OperatorInstance)),
 		).
-		Complete(r)
+		Watches(
+			&source.Kind{Type: &multusv1alpha1.LinkerdMultusConfig{}},
+			handler.EnqueueRequestsFromMapFunc(r.namespacesForSettings),
+			builder.WithPredicates(getSettingsEventFilter(r.Settings.ConfigName)),
+		)
+
+	if r.CNIDiscovery != nil {
+		blder = blder.Watches(
+			&source.Kind{Type: &appsv1.DaemonSet{}},
+			handler.EnqueueRequestsFromMapFunc(func(client.Object) []reconcile.Request {
+				return r.namespacesForCNIConfig("")
+			}),
+			builder.WithPredicates(getLinkerdCNIDaemonSetEventFilter()),
+		)
+	}
+
+	if r.DriftEvents != nil {
+		blder = blder.Watches(&source.Channel{Source: r.DriftEvents}, &handler.EnqueueRequestForObject{})
+	}
+
+	return r.CNIConfigSource.Watch(blder, r.namespacesForCNIConfig).Complete(r)
 }
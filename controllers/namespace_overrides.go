@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	k8s "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
+	"github.com/ErmakovDmitriy/linkerd-multus-attach-operator/settings"
+)
+
+// ErrInvalidNamespaceOverride is returned when a Namespace annotation which overrides
+// the Linkerd CNI configuration has an invalid value.
+var ErrInvalidNamespaceOverride = errors.New("invalid Linkerd CNI configuration override")
+
+// cniLogLevels are the log levels Linkerd CNI accepts.
+var cniLogLevels = []string{"panic", "fatal", "error", "warn", "warning", "info", "debug", "trace"}
+
+// renderCNIConfig returns the Linkerd CNI configuration of a namespace: the mesh's configuration
+// with the namespace annotation overrides and the proxy UID derived from the namespace UID range.
+// The mesh's configuration is not changed.
+func renderCNIConfig(meshConfig *CNIPluginConf, ns *corev1.Namespace, cfg settings.Settings) (*CNIPluginConf, error) {
+	config := *meshConfig
+	config.Linkerd.InboundPortsToIgnore = append([]string(nil), meshConfig.Linkerd.InboundPortsToIgnore...)
+	config.Linkerd.OutboundPortsToIgnore = append([]string(nil), meshConfig.Linkerd.OutboundPortsToIgnore...)
+
+	var err error
+
+	config.Linkerd.InboundPortsToIgnore, err = mergePortsAnnotation(config.Linkerd.InboundPortsToIgnore,
+		ns.Annotations, k8s.InboundPortsToIgnoreAnnotation)
+	if err != nil {
+		return nil, err
+	}
+
+	config.Linkerd.OutboundPortsToIgnore, err = mergePortsAnnotation(config.Linkerd.OutboundPortsToIgnore,
+		ns.Annotations, k8s.OutboundPortsToIgnoreAnnotation)
+	if err != nil {
+		return nil, err
+	}
+
+	if logLevel, ok := ns.Annotations[k8s.LogLevelAnnotation]; ok {
+		if !isKnownLogLevel(logLevel) {
+			return nil, fmt.Errorf("%w: %s annotation value %q, expected one of: %s", ErrInvalidNamespaceOverride,
+				k8s.LogLevelAnnotation, logLevel, strings.Join(cniLogLevels, ", "))
+		}
+
+		config.LogLevel = logLevel
+	}
+
+	// The webhook sets the same proxy UID to the Pods. As the webhook, the invalid range is ignored
+	// and the control plane namespaces are not changed.
+	if uidRange, ok := ns.Annotations[cfg.NamespaceUIDRangeAnnotation]; ok && cfg.MeshByLinkerdNamespace(ns.Name) == nil {
+		if proxyUID, err := k8s.ProxyUIDFromRange(uidRange, cfg.LinkerdProxyUIDOffset); err == nil {
+			config.Linkerd.ProxyUID = proxyUID
+		}
+	}
+
+	// The overrides must not make the valid mesh's configuration invalid, e.g. the proxy UID must not be root.
+	if err := validateCNIPluginConf(&config); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidNamespaceOverride, err.Error())
+	}
+
+	return &config, nil
+}
+
+// mergePortsAnnotation appends the ports of a namespace annotation which are not in the list yet.
+func mergePortsAnnotation(ports []string, annotations map[string]string, annotation string) ([]string, error) {
+	value, ok := annotations[annotation]
+	if !ok {
+		return ports, nil
+	}
+
+	for _, port := range strings.Split(value, ",") {
+		port = strings.TrimSpace(port)
+
+		if err := validatePortRange(port); err != nil {
+			return nil, fmt.Errorf("%w: %s annotation value %q: %s", ErrInvalidNamespaceOverride,
+				annotation, value, err.Error())
+		}
+
+		if !containsString(ports, port) {
+			ports = append(ports, port)
+		}
+	}
+
+	return ports, nil
+}
+
+// validatePortRange checks that a value is a port or a "{{ first port }}-{{ last port }}" range.
+func validatePortRange(value string) error {
+	bounds := strings.SplitN(value, "-", 2)
+
+	first, err := parsePort(bounds[0])
+	if err != nil {
+		return err
+	}
+
+	if len(bounds) == 1 {
+		return nil
+	}
+
+	last, err := parsePort(bounds[1])
+	if err != nil {
+		return err
+	}
+
+	if first > last {
+		return fmt.Errorf("port range %q is reversed", value)
+	}
+
+	return nil
+}
+
+// parsePort parses a port number in 1-65535 range.
+func parsePort(value string) (int, error) {
+	port, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("port %q is not a number", value)
+	}
+
+	if port < 1 || port > 65535 {
+		return 0, fmt.Errorf("port %d is out of 1-65535 range", port)
+	}
+
+	return port, nil
+}
+
+func isKnownLogLevel(level string) bool {
+	return containsString(cniLogLevels, level)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
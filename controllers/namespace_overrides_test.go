@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/containernetworking/cni/pkg/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8s "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
+	"github.com/ErmakovDmitriy/linkerd-multus-attach-operator/settings"
+)
+
+const testUIDRangeAnnotation = "openshift.io/sa.scc.uid-range"
+
+var testOverrideSettings = settings.Settings{
+	LinkerdNamespace:            "linkerd",
+	NamespaceUIDRangeAnnotation: testUIDRangeAnnotation,
+	LinkerdProxyUIDOffset:       2102,
+}
+
+// newTestCNIPluginConf returns a valid Linkerd CNI configuration.
+func newTestCNIPluginConf() *CNIPluginConf {
+	return &CNIPluginConf{
+		NetConf:    types.NetConf{Name: k8s.MultusNetworkAttachmentDefinitionName, Type: k8s.MultusCNIType},
+		Kubernetes: Kubernetes{Kubeconfig: "/etc/cni/net.d/ZZZ-linkerd-cni-kubeconfig"},
+		Linkerd: ProxyInit{
+			IncomingProxyPort:    4143,
+			OutgoingProxyPort:    4140,
+			ProxyUID:             2102,
+			InboundPortsToIgnore: []string{"25"},
+		},
+	}
+}
+
+func TestRenderCNIConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		namespace   string
+		annotations map[string]string
+		expected    func(c *CNIPluginConf)
+	}{
+		{
+			name:     "no overrides",
+			expected: func(c *CNIPluginConf) {},
+		},
+		{
+			name: "ports are appended without duplicates",
+			annotations: map[string]string{
+				k8s.InboundPortsToIgnoreAnnotation:  "25, 8080-8090",
+				k8s.OutboundPortsToIgnoreAnnotation: "443",
+			},
+			expected: func(c *CNIPluginConf) {
+				c.Linkerd.InboundPortsToIgnore = []string{"25", "8080-8090"}
+				c.Linkerd.OutboundPortsToIgnore = []string{"443"}
+			},
+		},
+		{
+			name:        "log level",
+			annotations: map[string]string{k8s.LogLevelAnnotation: "debug"},
+			expected:    func(c *CNIPluginConf) { c.LogLevel = "debug" },
+		},
+		{
+			name:        "proxy UID from the UID range",
+			annotations: map[string]string{testUIDRangeAnnotation: "1000/10000"},
+			expected:    func(c *CNIPluginConf) { c.Linkerd.ProxyUID = 3102 },
+		},
+		{
+			name:        "invalid UID range is ignored",
+			annotations: map[string]string{testUIDRangeAnnotation: "1000"},
+			expected:    func(c *CNIPluginConf) {},
+		},
+		{
+			name:        "control plane namespace keeps the proxy UID",
+			namespace:   "linkerd",
+			annotations: map[string]string{testUIDRangeAnnotation: "1000/10000"},
+			expected:    func(c *CNIPluginConf) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meshConfig := newTestCNIPluginConf()
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: tt.namespace, Annotations: tt.annotations}}
+
+			got, err := renderCNIConfig(meshConfig, ns, testOverrideSettings)
+			if err != nil {
+				t.Fatalf("renderCNIConfig() error = %v", err)
+			}
+
+			expected := newTestCNIPluginConf()
+			tt.expected(expected)
+
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("renderCNIConfig() = %+v, want %+v", got, expected)
+			}
+
+			if !reflect.DeepEqual(meshConfig, newTestCNIPluginConf()) {
+				t.Errorf("renderCNIConfig() changed the mesh configuration to %+v", meshConfig)
+			}
+		})
+	}
+}
+
+func TestRenderCNIConfigInvalidOverride(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+	}{
+		{"port is not a number", map[string]string{k8s.InboundPortsToIgnoreAnnotation: "http"}},
+		{"port is out of range", map[string]string{k8s.OutboundPortsToIgnoreAnnotation: "65536"}},
+		{"reversed port range", map[string]string{k8s.InboundPortsToIgnoreAnnotation: "8090-8080"}},
+		{"empty port", map[string]string{k8s.InboundPortsToIgnoreAnnotation: "25,"}},
+		{"unknown log level", map[string]string{k8s.LogLevelAnnotation: "verbose"}},
+		{"root proxy UID", map[string]string{testUIDRangeAnnotation: "-2102/10000"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app", Annotations: tt.annotations}}
+
+			if _, err := renderCNIConfig(newTestCNIPluginConf(), ns, testOverrideSettings); !errors.Is(err, ErrInvalidNamespaceOverride) {
+				t.Errorf("renderCNIConfig() error = %v, want %v", err, ErrInvalidNamespaceOverride)
+			}
+		})
+	}
+}
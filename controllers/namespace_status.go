@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	k8s "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Reconciliation results. They are used as the NamespaceStatus.Result values and,
+// for the results which are reported as Kubernetes Events, as the Events' reasons.
+const (
+	// ResultCreated - NetworkAttachmentDefinition was created.
+	ResultCreated = "Created"
+	// ResultUpdated - NetworkAttachmentDefinition was updated.
+	ResultUpdated = "Updated"
+	// ResultDeleted - NetworkAttachmentDefinition was deleted.
+	ResultDeleted = "Deleted"
+	// ResultAdopted - NetworkAttachmentDefinition not managed by the operator was adopted.
+	ResultAdopted = "Adopted"
+	// ResultInSync - NetworkAttachmentDefinition is up to date.
+	ResultInSync = "InSync"
+	// ResultNotRequired - NetworkAttachmentDefinition is not required and not present.
+	ResultNotRequired = "NotRequired"
+	// ResultIgnored - NetworkAttachmentDefinition is not managed by the operator and is left untouched.
+	ResultIgnored = "Ignored"
+	// ResultNotManaged - NetworkAttachmentDefinition is not managed by the operator and can not be adopted.
+	ResultNotManaged = "NotManaged"
+	// ResultUnknownMesh - Namespace references Linkerd control plane which is not configured.
+	ResultUnknownMesh = "UnknownMesh"
+	// ResultInvalidName - Namespace annotation contains invalid NetworkAttachmentDefinition name.
+	ResultInvalidName = "InvalidName"
+	// ResultInvalidOverride - Namespace annotation which overrides Linkerd CNI configuration is not valid.
+	ResultInvalidOverride = "InvalidOverride"
+	// ResultInvalidChain - Configured CNI plugin chain can not be rendered with Linkerd CNI configuration.
+	ResultInvalidChain = "InvalidChain"
+	// ResultUnsupportedCNIVersion - NetworkAttachmentDefinition can not be rendered with a CNI version Linkerd CNI supports.
+	ResultUnsupportedCNIVersion = "UnsupportedCNIVersion"
+	// ResultCNIChained - Linkerd CNI is chained into the primary CNI configuration, attaching it would apply it twice.
+	ResultCNIChained = "CNIChained"
+	// ResultConfigMissing - Linkerd CNI ConfigMap does not exist.
+	ResultConfigMissing = "ConfigMissing"
+	// ResultConfigInvalid - Linkerd CNI ConfigMap content is not valid.
+	ResultConfigInvalid = "ConfigInvalid"
+	// ResultNamespaceNotCached - Linkerd CNI configuration is in a namespace the operator does not read it in.
+	ResultNamespaceNotCached = "NamespaceNotCached"
+	// ResultWouldCreate - NetworkAttachmentDefinition would be created in Audit mode.
+	ResultWouldCreate = "WouldCreate"
+	// ResultWouldUpdate - NetworkAttachmentDefinition would be updated in Audit mode.
+	ResultWouldUpdate = "WouldUpdate"
+	// ResultWouldDelete - NetworkAttachmentDefinition would be deleted in Audit mode.
+	ResultWouldDelete = "WouldDelete"
+	// ResultFailed - Kubernetes API call failed.
+	ResultFailed = "Failed"
+)
+
+// NamespaceStatus is the last reconciliation result which is stored in
+// the NamespaceStatusAnnotation annotation of a Namespace.
+type NamespaceStatus struct {
+	// Result is one of the Result* constants.
+	Result string `json:"result"`
+	// Message is a human readable details of the Result.
+	Message string `json:"message,omitempty"`
+	// ConfigHash is a hash of the CNI configuration the NetworkAttachmentDefinition
+	// was rendered from.
+	ConfigHash string `json:"configHash,omitempty"`
+	// Timestamp is the time of the Result in RFC3339 format.
+	Timestamp string `json:"timestamp"`
+}
+
+// isStatusChanged checks if the new status must be written over the current one.
+// Timestamps are not compared as otherwise every reconciliation would change the Namespace.
+// InSync status does not replace Created or Updated status with the same config hash
+// so the last applied change is kept.
+func isStatusChanged(current, required *NamespaceStatus) bool {
+	if current == nil {
+		return true
+	}
+
+	if required.Result == ResultInSync && current.ConfigHash == required.ConfigHash &&
+		(current.Result == ResultCreated || current.Result == ResultUpdated || current.Result == ResultAdopted) {
+		return false
+	}
+
+	return current.Result != required.Result ||
+		current.Message != required.Message ||
+		current.ConfigHash != required.ConfigHash
+}
+
+// getNamespaceStatus parses the NamespaceStatusAnnotation annotation of a Namespace.
+// Returns nil, if the annotation is not set or can not be parsed.
+func getNamespaceStatus(ns *corev1.Namespace) *NamespaceStatus {
+	raw, ok := ns.Annotations[k8s.NamespaceStatusAnnotation]
+	if !ok {
+		return nil
+	}
+
+	var status = &NamespaceStatus{}
+
+	if err := json.Unmarshal([]byte(raw), status); err != nil {
+		return nil
+	}
+
+	return status
+}
+
+// setNamespaceStatus patches the NamespaceStatusAnnotation of a Namespace, if the status has changed.
+func setNamespaceStatus(ctx context.Context, c client.Client, ns *corev1.Namespace, status *NamespaceStatus) error {
+	if !isStatusChanged(getNamespaceStatus(ns), status) {
+		return nil
+	}
+
+	status.Timestamp = time.Now().UTC().Format(time.RFC3339)
+
+	raw, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("can not JSON Marshal Namespace status: %w", err)
+	}
+
+	patch := client.MergeFrom(ns.DeepCopy())
+
+	if ns.Annotations == nil {
+		ns.Annotations = make(map[string]string, 1)
+	}
+
+	ns.Annotations[k8s.NamespaceStatusAnnotation] = string(raw)
+
+	if err := c.Patch(ctx, ns, patch); err != nil {
+		return fmt.Errorf("can not patch Namespace %s status annotation: %w", ns.Name, err)
+	}
+
+	return nil
+}
+
+// report records the reconciliation result as a Kubernetes Event, if eventType is not empty,
+// and in the Namespace status annotation.
+func (r *NamespaceReconciler) report(ctx context.Context, logger logr.Logger, ns *corev1.Namespace,
+	eventType, result, message, configHash string) {
+	if eventType != "" {
+		r.Recorder.Event(ns, eventType, result, message)
+	}
+
+	if err := setNamespaceStatus(ctx, r.Client, ns, &NamespaceStatus{
+		Result:     result,
+		Message:    message,
+		ConfigHash: configHash,
+	}); err != nil {
+		logger.Error(err, "can not set Namespace status")
+	}
+}
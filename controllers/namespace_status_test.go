@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8s "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
+)
+
+func TestIsStatusChanged(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  *NamespaceStatus
+		required *NamespaceStatus
+		expected bool
+	}{
+		{
+			name:     "no current status",
+			required: &NamespaceStatus{Result: ResultInSync},
+			expected: true,
+		},
+		{
+			name:     "same status with other timestamp",
+			current:  &NamespaceStatus{Result: ResultNotRequired, Timestamp: "2022-01-01T00:00:00Z"},
+			required: &NamespaceStatus{Result: ResultNotRequired, Timestamp: "2022-01-02T00:00:00Z"},
+			expected: false,
+		},
+		{
+			name:     "other result",
+			current:  &NamespaceStatus{Result: ResultInSync, ConfigHash: "a"},
+			required: &NamespaceStatus{Result: ResultFailed, ConfigHash: "a"},
+			expected: true,
+		},
+		{
+			name:     "other message",
+			current:  &NamespaceStatus{Result: ResultFailed, Message: "a"},
+			required: &NamespaceStatus{Result: ResultFailed, Message: "b"},
+			expected: true,
+		},
+		{
+			name:     "other config hash",
+			current:  &NamespaceStatus{Result: ResultInSync, ConfigHash: "a"},
+			required: &NamespaceStatus{Result: ResultInSync, ConfigHash: "b"},
+			expected: true,
+		},
+		{
+			name:     "in sync keeps created",
+			current:  &NamespaceStatus{Result: ResultCreated, Message: "Created", ConfigHash: "a"},
+			required: &NamespaceStatus{Result: ResultInSync, ConfigHash: "a"},
+			expected: false,
+		},
+		{
+			name:     "in sync keeps updated",
+			current:  &NamespaceStatus{Result: ResultUpdated, Message: "Updated", ConfigHash: "a"},
+			required: &NamespaceStatus{Result: ResultInSync, ConfigHash: "a"},
+			expected: false,
+		},
+		{
+			name:     "in sync keeps adopted",
+			current:  &NamespaceStatus{Result: ResultAdopted, Message: "Adopted", ConfigHash: "a"},
+			required: &NamespaceStatus{Result: ResultInSync, ConfigHash: "a"},
+			expected: false,
+		},
+		{
+			name:     "in sync replaces created with other config hash",
+			current:  &NamespaceStatus{Result: ResultCreated, ConfigHash: "a"},
+			required: &NamespaceStatus{Result: ResultInSync, ConfigHash: "b"},
+			expected: true,
+		},
+		{
+			name:     "in sync replaces failed",
+			current:  &NamespaceStatus{Result: ResultFailed, ConfigHash: "a"},
+			required: &NamespaceStatus{Result: ResultInSync, ConfigHash: "a"},
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isStatusChanged(tt.current, tt.required); got != tt.expected {
+				t.Errorf("isStatusChanged() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetNamespaceStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    *NamespaceStatus
+	}{
+		{
+			name: "no annotation",
+		},
+		{
+			name:        "malformed annotation",
+			annotations: map[string]string{k8s.NamespaceStatusAnnotation: "{"},
+		},
+		{
+			name: "status",
+			annotations: map[string]string{
+				k8s.NamespaceStatusAnnotation: `{"result":"Created","configHash":"a","timestamp":"2022-01-01T00:00:00Z"}`,
+			},
+			expected: &NamespaceStatus{Result: ResultCreated, ConfigHash: "a", Timestamp: "2022-01-01T00:00:00Z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getNamespaceStatus(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}})
+
+			switch {
+			case tt.expected == nil && got != nil:
+				t.Errorf("getNamespaceStatus() = %+v, want nil", got)
+			case tt.expected != nil && (got == nil || *got != *tt.expected):
+				t.Errorf("getNamespaceStatus() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	k8s "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
+	"github.com/go-logr/logr"
+	netattachv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+)
+
+// ReasonDeletionBlocked is the reason of the Events about NetworkAttachmentDefinitions
+// which deletion is blocked by the Pods using them.
+const ReasonDeletionBlocked = "DeletionBlocked"
+
+// netAttachInUseRequeueAfter is the period of the checks if a NetworkAttachmentDefinition being deleted
+// is still used by Pods. Pods are not watched to avoid caching all the Pods in the cluster.
+const netAttachInUseRequeueAfter = 30 * time.Second
+
+// maxBlockingPods limits the number of Pods listed in a DeletionBlocked Event.
+const maxBlockingPods = 10
+
+// finalizeNetAttaches removes the NetAttachFinalizer from the managed NetworkAttachmentDefinitions
+// being deleted in a namespace, if no Pod in the namespace lists them in its networks annotation.
+// The finalizer is removed regardless of the NamespaceReconciler.InUseProtection, so disabling
+// the protection does not leave the NetworkAttachmentDefinitions being deleted stuck.
+// Returns true, if a NetworkAttachmentDefinition deletion is still blocked.
+func (r *NamespaceReconciler) finalizeNetAttaches(ctx context.Context, logger logr.Logger,
+	ns *corev1.Namespace) (bool, error) {
+	var netAttaches = &netattachv1.NetworkAttachmentDefinitionList{}
+
+	if err := r.List(ctx, netAttaches, client.InNamespace(ns.Name),
+		client.MatchingLabels(managedLabels(r.OperatorInstance))); err != nil {
+		return false, fmt.Errorf("can not list Multus NetworkAttachmentDefinitions: %w", err)
+	}
+
+	var (
+		pods      *corev1.PodList
+		isBlocked bool
+	)
+
+	for i := range netAttaches.Items {
+		netAttach := &netAttaches.Items[i]
+
+		if netAttach.DeletionTimestamp.IsZero() || !controllerutil.ContainsFinalizer(netAttach, k8s.NetAttachFinalizer) {
+			continue
+		}
+
+		// The Pods are listed once and only if there is a NetworkAttachmentDefinition to finalize.
+		if pods == nil {
+			pods = &corev1.PodList{}
+
+			if err := r.APIReader.List(ctx, pods, client.InNamespace(ns.Name)); err != nil {
+				return false, fmt.Errorf("can not list Pods: %w", err)
+			}
+		}
+
+		netAttachRef := client.ObjectKeyFromObject(netAttach).String()
+
+		if blockingPods := podsUsingNetAttach(logger, pods.Items, netAttach); len(blockingPods) != 0 {
+			isBlocked = true
+
+			logger.Info("Multus NetworkAttachmentDefinition deletion is blocked by Pods",
+				"name", netAttachRef, "pods", blockingPods)
+
+			if len(blockingPods) > maxBlockingPods {
+				blockingPods = append(blockingPods[:maxBlockingPods],
+					fmt.Sprintf("and %d more", len(blockingPods)-maxBlockingPods))
+			}
+
+			r.Recorder.Event(ns, corev1.EventTypeWarning, ReasonDeletionBlocked,
+				"NetworkAttachmentDefinition "+netAttachRef+" deletion is blocked by Pods: "+strings.Join(blockingPods, ", "))
+
+			continue
+		}
+
+		patch := client.MergeFromWithOptions(netAttach.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		controllerutil.RemoveFinalizer(netAttach, k8s.NetAttachFinalizer)
+
+		if err := r.Patch(ctx, netAttach, patch); err != nil {
+			return isBlocked, fmt.Errorf("can not remove finalizer from Multus NetworkAttachmentDefinition %s: %w",
+				netAttachRef, err)
+		}
+
+		logger.V(debugLogLevel).Info("Multus NetworkAttachmentDefinition is not used, finalizer removed", "name", netAttachRef)
+	}
+
+	return isBlocked, nil
+}
+
+// podsUsingNetAttach returns the names of the not finished Pods which list the NetworkAttachmentDefinition
+// in the MultusNetworkAttachAnnotation annotation.
+func podsUsingNetAttach(logger logr.Logger, pods []corev1.Pod, netAttach *netattachv1.NetworkAttachmentDefinition) []string {
+	var names []string
+
+	for i := range pods {
+		pod := &pods[i]
+
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+
+		networks := pod.Annotations[k8s.MultusNetworkAttachAnnotation]
+		if networks == "" {
+			continue
+		}
+
+		selection, err := k8s.ParseNetworkSelection(networks)
+		if err != nil {
+			logger.Info("Pod networks annotation can not be parsed, ignoring the Pod",
+				"pod", pod.Name, "reason", err.Error())
+
+			continue
+		}
+
+		if selection.Contains(pod.Namespace, netAttach.Namespace, netAttach.Name) {
+			names = append(names, pod.Name)
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8s "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
+)
+
+// newTestPod returns a Pod with the networks annotation, an empty value means no annotation.
+func newTestPod(name, namespace, networks string, phase corev1.PodPhase) corev1.Pod {
+	var pod = corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Annotations: map[string]string{}},
+		Status:     corev1.PodStatus{Phase: phase},
+	}
+
+	if networks != "" {
+		pod.Annotations[k8s.MultusNetworkAttachAnnotation] = networks
+	}
+
+	return pod
+}
+
+func TestPodsUsingNetAttach(t *testing.T) {
+	tests := []struct {
+		name     string
+		pod      corev1.Pod
+		expected []string
+	}{
+		{"no networks", newTestPod("a", "app", "", corev1.PodRunning), nil},
+		{"text list", newTestPod("a", "app", "macvlan,linkerd-cni", corev1.PodRunning), []string{"a"}},
+		{"text list with namespace and interface", newTestPod("a", "app", "app/linkerd-cni@eth1", corev1.PodPending),
+			[]string{"a"}},
+		{"JSON list", newTestPod("a", "app", `[{"name":"linkerd-cni","namespace":"app"}]`, corev1.PodRunning),
+			[]string{"a"}},
+		{"other network", newTestPod("a", "app", "macvlan", corev1.PodRunning), nil},
+		{"other namespace", newTestPod("a", "app", "other/linkerd-cni", corev1.PodRunning), nil},
+		{"succeeded Pod", newTestPod("a", "app", "linkerd-cni", corev1.PodSucceeded), nil},
+		{"failed Pod", newTestPod("a", "app", "linkerd-cni", corev1.PodFailed), nil},
+		{"malformed annotation", newTestPod("a", "app", "linkerd-cni@", corev1.PodRunning), nil},
+	}
+
+	netAttach := newTestNetAttach(testLinkerdCNIConfig, k8s.NetAttachFinalizer)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := podsUsingNetAttach(logr.Discard(), []corev1.Pod{tt.pod}, netAttach)
+
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("podsUsingNetAttach() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+
+	t.Run("several Pods", func(t *testing.T) {
+		pods := []corev1.Pod{
+			newTestPod("a", "app", "linkerd-cni", corev1.PodRunning),
+			newTestPod("b", "app", "macvlan", corev1.PodRunning),
+			newTestPod("c", "app", "linkerd-cni", corev1.PodRunning),
+		}
+
+		if got, expected := podsUsingNetAttach(logr.Discard(), pods, netAttach), []string{"a", "c"}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("podsUsingNetAttach() = %q, want %q", got, expected)
+		}
+	})
+}
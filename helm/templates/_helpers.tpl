@@ -59,4 +59,102 @@ Create the name of the service account to use
 {{- else }}
 {{- default "default" .Values.serviceAccount.name }}
 {{- end }}
-{{- end }}
\ No newline at end of file
+{{- end }}
+{{/*
+Rules of the namespaced resources the controller manager uses, granted
+cluster-wide or in every namespace of the namespace scope.
+*/}}
+{{- define "multus-attacher.namespacedRules" -}}
+- apiGroups:
+  - ""
+  resources:
+  - configmaps
+  verbs:
+  - get
+  - list
+  - watch
+- apiGroups:
+  - ""
+  resources:
+  - pods
+  verbs:
+  - get
+  - list
+  - watch
+- apiGroups:
+  - apps
+  resources:
+  - daemonsets
+  verbs:
+  - get
+  - list
+  - watch
+- apiGroups:
+  - k8s.cni.cncf.io
+  resources:
+  - network-attachment-definitions
+  verbs:
+  - create
+  - delete
+  - get
+  - list
+  - patch
+  - update
+  - watch
+{{- end }}
+
+{{/*
+Linkerd-CNI namespaces of the meshes: controller.cniNamespace and controller.extraCniNamespaces.
+*/}}
+{{- define "multus-attacher.cniNamespaces" -}}
+{{- $namespaces := list .Values.controller.cniNamespace -}}
+{{- range .Values.controller.extraCniNamespaces -}}
+{{- if not (has . $namespaces) -}}
+{{- $namespaces = append $namespaces . -}}
+{{- end -}}
+{{- end -}}
+{{- join "," $namespaces }}
+{{- end }}
+
+{{/*
+Namespaces the controller manager caches the namespaced resources in:
+the namespace scope list, the Linkerd-CNI namespaces and the Linkerd control plane namespace.
+*/}}
+{{- define "multus-attacher.scopeNamespaces" -}}
+{{- $namespaces := .Values.controller.namespaceScope.namespaces -}}
+{{- $system := append (splitList "," (include "multus-attacher.cniNamespaces" .)) .Values.controller.linkerdControlPlaneNamespace -}}
+{{- range $system -}}
+{{- if not (has . $namespaces) -}}
+{{- $namespaces = append $namespaces . -}}
+{{- end -}}
+{{- end -}}
+{{- join "," $namespaces }}
+{{- end }}
+
+{{/*
+Label selector of the namespaces in the scope in the "key=value,..." form.
+*/}}
+{{- define "multus-attacher.scopeSelector" -}}
+{{- $requirements := list -}}
+{{- range $key, $value := .Values.controller.namespaceScope.matchLabels -}}
+{{- $requirements = append $requirements (printf "%s=%s" $key $value) -}}
+{{- end -}}
+{{- join "," $requirements }}
+{{- end }}
+
+{{/*
+Namespace selector of the mutating webhook: the webhook.namespaceSelector value
+restricted to the namespace scope.
+*/}}
+{{- define "multus-attacher.webhookNamespaceSelector" -}}
+{{- $selector := tpl (toYaml .Values.webhook.namespaceSelector) . | fromYaml -}}
+{{- with .Values.controller.namespaceScope.namespaces -}}
+{{- $expressions := get $selector "matchExpressions" | default list -}}
+{{- $expressions = append $expressions (dict "key" "kubernetes.io/metadata.name" "operator" "In" "values" .) -}}
+{{- $_ := set $selector "matchExpressions" $expressions -}}
+{{- end -}}
+{{- with .Values.controller.namespaceScope.matchLabels -}}
+{{- $_ := set $selector "matchLabels" (merge (dict) . (get $selector "matchLabels" | default dict)) -}}
+{{- end -}}
+{{- toYaml $selector }}
+{{- end }}
@@ -8,8 +8,14 @@ import (
 const (
 	// MultusAttachAnnotation - annotation name to mark a namespace to create Multus NetworkAttach Definition in.
 	MultusAttachAnnotation = pkgK8s.Prefix + "/multus"
+	// MultusAttachLabelDefault - default label name which enables Multus NetworkAttach Definition
+	// in a namespace or for a pod in the same way as MultusAttachAnnotation.
+	MultusAttachLabelDefault = "multus.linkerd.io/attach"
 	// LinkerdInjectAnnotation - pod or namespace annotation which enables Linkerd proxy inject.
 	LinkerdInjectAnnotation = pkgK8s.ProxyInjectAnnotation
+	// LinkerdControlPlaneNamespaceLabel - Namespace label (or annotation) which selects the Linkerd
+	// control plane, if there are several in a cluster. The Linkerd proxy injector sets it on the Pods too.
+	LinkerdControlPlaneNamespaceLabel = pkgK8s.ControllerNSLabel
 	// LinkerdProxyUIDAnnotation - annotation to set Linkerd proxy UID.
 	LinkerdProxyUIDAnnotation = pkgK8s.ProxyUIDAnnotation
 
@@ -17,7 +23,66 @@ const (
 	// created in a namespace if MultusAttachAnnotation is enabled.
 	MultusNetworkAttachmentDefinitionName = "linkerd-cni"
 
-	// MultusCNIVersion is a CNI version implemented by Linkerd.
+	// ManagedByLabel is a well-known label which marks NetworkAttachmentDefinitions
+	// managed by the operator.
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+
+	// ManagedByLabelValue is the ManagedByLabel value set by the operator.
+	ManagedByLabelValue = "linkerd-multus-attach-operator"
+
+	// OperatorInstanceLabel contains the name of the operator instance which manages
+	// a NetworkAttachmentDefinition. It allows several operator instances to run in one cluster.
+	OperatorInstanceLabel = "multus.linkerd.io/instance"
+
+	// OperatorInstanceDefault is the default OperatorInstanceLabel value.
+	OperatorInstanceDefault = "default"
+
+	// NamespaceStatusAnnotation is a Namespace annotation in which the controller records
+	// the last reconciliation result in JSON format.
+	NamespaceStatusAnnotation = "multus.linkerd.io/status"
+
+	// NetAttachFinalizer - finalizer of the managed NetworkAttachmentDefinitions which holds
+	// their deletion while Pods in the namespace list them in MultusNetworkAttachAnnotation.
+	NetAttachFinalizer = "multus.linkerd.io/in-use-protection"
+
+	// WebhookAuditPatchAnnotation - audit annotation in which the webhook records the would-be
+	// Pod patch in Audit mode. The API server prefixes it with the webhook name.
+	WebhookAuditPatchAnnotation = "would-patch"
+
+	// PodTemplateMutatedAnnotation - workload Pod template annotation which the workload webhook sets
+	// to PodTemplateMutatedValue. The Pods created from the template inherit it. It is informational only
+	// as anyone can set it, the Pod webhook still checks such Pods.
+	PodTemplateMutatedAnnotation = "multus.linkerd.io/template-mutated"
+	// PodTemplateMutatedValue is assigned to PodTemplateMutatedAnnotation.
+	PodTemplateMutatedValue = "true"
+
+	// PodTemplateNetworkAnnotation - workload Pod template annotation in which the workload webhook records
+	// the NetworkAttachmentDefinition reference it added to the template, so the reference can be replaced
+	// when the NetworkAttachmentDefinition is renamed or the mesh is changed.
+	PodTemplateNetworkAnnotation = "multus.linkerd.io/template-network"
+
+	// NetworkAttachmentDefinitionNameAnnotation - Namespace annotation which overrides
+	// the name of the NetworkAttachmentDefinition created in the namespace.
+	NetworkAttachmentDefinitionNameAnnotation = "multus.linkerd.io/network-attachment-definition-name"
+
+	// PodNetworkAttachmentDefinitionAnnotation - Pod annotation which references an existing
+	// NetworkAttachmentDefinition as "{{ namespace }}/{{ name }}" or "{{ name }}" to attach
+	// the Pod to instead of the one managed by the operator.
+	PodNetworkAttachmentDefinitionAnnotation = "multus.linkerd.io/network-attachment-definition"
+
+	// InboundPortsToIgnoreAnnotation - Namespace annotation with a comma-separated list of ports
+	// or port ranges which are added to the Linkerd CNI inbound-ports-to-ignore of the namespace.
+	InboundPortsToIgnoreAnnotation = "multus.linkerd.io/inbound-ports-to-ignore"
+
+	// OutboundPortsToIgnoreAnnotation - Namespace annotation with a comma-separated list of ports
+	// or port ranges which are added to the Linkerd CNI outbound-ports-to-ignore of the namespace.
+	OutboundPortsToIgnoreAnnotation = "multus.linkerd.io/outbound-ports-to-ignore"
+
+	// LogLevelAnnotation - Namespace annotation which overrides the Linkerd CNI log_level of the namespace.
+	LogLevelAnnotation = "multus.linkerd.io/log-level"
+
+	// MultusCNIVersion is a CNI version implemented by Linkerd, it is used when
+	// the Linkerd CNI configuration does not set the version.
 	MultusCNIVersion = "0.3.0"
 
 	// MultusCNIType is Linkerd CNI type field value.
@@ -37,6 +102,30 @@ const (
 	// which stores Linkerd CNI config.
 	LinkerdCNIConfigMapKey = "cni_network_config"
 
+	// LinkerdCNIDaemonSetLabel is the label of the Linkerd CNI DaemonSet.
+	LinkerdCNIDaemonSetLabel = "k8s-app"
+
+	// LinkerdCNIDaemonSetLabelValue is the LinkerdCNIDaemonSetLabel value of the Linkerd CNI DaemonSet.
+	LinkerdCNIDaemonSetLabelValue = "linkerd-cni"
+
+	// LinkerdCNIResourceLabel marks the resources of a Linkerd CNI installation, e.g. its ConfigMap.
+	LinkerdCNIResourceLabel = "linkerd.io/cni-resource"
+
+	// LinkerdCNINetworkConfigEnv is the Linkerd CNI installer environment variable
+	// which gets the Linkerd CNI config from the ConfigMap.
+	LinkerdCNINetworkConfigEnv = "CNI_NETWORK_CONFIG"
+
+	// LinkerdCNINetDirEnv is the Linkerd CNI installer environment variable with the host
+	// directory the installer writes the CNI configuration to.
+	LinkerdCNINetDirEnv = "DEST_CNI_NET_DIR"
+
+	// LinkerdCNIHostNetDirMountPath is the Linkerd CNI installer mount path of the host CNI configuration directory.
+	LinkerdCNIHostNetDirMountPath = "/host/etc/cni/net.d"
+
+	// PrimaryCNINetDirDefault is the default host directory of the primary CNI configuration.
+	// Linkerd CNI installed there is chained into the primary CNI configuration of all the Pods.
+	PrimaryCNINetDirDefault = "/etc/cni/net.d"
+
 	// MultusAttachEnabled is assigned to MultusAttachAnnotation to enable
 	// NetworkAttachmentDefinition creation in a namespace.
 	MultusAttachEnabled = pkgK8s.Enabled
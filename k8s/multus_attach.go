@@ -0,0 +1,18 @@
+package k8s
+
+// MultusAttachValue returns the MultusAttachAnnotation value of an object
+// taking the opt-in label into account.
+// The annotation takes precedence: the label is used only if the annotation is not set or empty,
+// so an object with "linkerd.io/multus: disabled" annotation and the label set to "enabled"
+// is not attached. An empty attachLabel disables the label opt-in.
+func MultusAttachValue(annotations, labels map[string]string, attachLabel string) string {
+	if val := annotations[MultusAttachAnnotation]; val != "" {
+		return val
+	}
+
+	if attachLabel == "" {
+		return ""
+	}
+
+	return labels[attachLabel]
+}
@@ -0,0 +1,35 @@
+package k8s
+
+import "testing"
+
+const testAttachLabel = "multus.linkerd.io/attach"
+
+func TestMultusAttachValue(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		labels      map[string]string
+		attachLabel string
+		expected    string
+	}{
+		{"nothing is set", nil, nil, testAttachLabel, ""},
+		{"label only", nil, map[string]string{testAttachLabel: MultusAttachEnabled}, testAttachLabel, MultusAttachEnabled},
+		{"annotation only", map[string]string{MultusAttachAnnotation: MultusAttachEnabled}, nil, testAttachLabel,
+			MultusAttachEnabled},
+		{"label and annotation agree", map[string]string{MultusAttachAnnotation: MultusAttachEnabled},
+			map[string]string{testAttachLabel: MultusAttachEnabled}, testAttachLabel, MultusAttachEnabled},
+		{"annotation wins over the conflicting label", map[string]string{MultusAttachAnnotation: MultusAttachDisabled},
+			map[string]string{testAttachLabel: MultusAttachEnabled}, testAttachLabel, MultusAttachDisabled},
+		{"empty annotation does not hide the label", map[string]string{MultusAttachAnnotation: ""},
+			map[string]string{testAttachLabel: MultusAttachEnabled}, testAttachLabel, MultusAttachEnabled},
+		{"label is disabled", nil, map[string]string{testAttachLabel: MultusAttachEnabled}, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MultusAttachValue(tt.annotations, tt.labels, tt.attachLabel); got != tt.expected {
+				t.Errorf("MultusAttachValue() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
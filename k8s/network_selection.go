@@ -0,0 +1,238 @@
+package k8s
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidNetworkSelection is returned when a MultusNetworkAttachAnnotation value can not be parsed.
+var ErrInvalidNetworkSelection = errors.New("invalid Multus network selection annotation")
+
+// NetworkSelectionFormat is the syntax of a MultusNetworkAttachAnnotation value.
+type NetworkSelectionFormat string
+
+const (
+	// NetworkSelectionFormatText - comma-separated "[<namespace>/]<name>[@<interface>]" list.
+	NetworkSelectionFormatText NetworkSelectionFormat = "text"
+	// NetworkSelectionFormatJSON - JSON list of the network selection objects.
+	NetworkSelectionFormatJSON NetworkSelectionFormat = "json"
+)
+
+// networkSelectionJSONChars are the characters Multus detects the JSON format by.
+const networkSelectionJSONChars = `[{"`
+
+// NetworkSelectionElement is a network of a MultusNetworkAttachAnnotation value.
+type NetworkSelectionElement struct {
+	// Name is the NetworkAttachmentDefinition name.
+	Name string `json:"name"`
+	// Namespace is the NetworkAttachmentDefinition namespace, empty means the Pod's namespace.
+	Namespace string `json:"namespace,omitempty"`
+	// Interface is the Pod interface name, empty means the name generated by Multus.
+	Interface string `json:"interface,omitempty"`
+
+	// raw is the JSON object the element is parsed from, it is serialized as is
+	// to keep the fields the operator does not know, e.g. "ips" or "mac".
+	raw json.RawMessage
+}
+
+// Selects reports whether the element selects the NetworkAttachmentDefinition namespace/name.
+// The empty namespaces are the Pod's one.
+func (e *NetworkSelectionElement) Selects(podNamespace, namespace, name string) bool {
+	return e.Name == name && defaultNamespace(e.Namespace, podNamespace) == defaultNamespace(namespace, podNamespace)
+}
+
+// String returns the element in the text format.
+func (e *NetworkSelectionElement) String() string {
+	var text = e.Name
+
+	if e.Namespace != "" {
+		text = e.Namespace + "/" + text
+	}
+
+	if e.Interface != "" {
+		text += "@" + e.Interface
+	}
+
+	return text
+}
+
+// NetworkSelection is a parsed MultusNetworkAttachAnnotation value.
+type NetworkSelection struct {
+	// Format is the syntax the value is written in, it is kept on serialization.
+	Format NetworkSelectionFormat
+	// Elements are the selected networks in the annotation order.
+	Elements []*NetworkSelectionElement
+}
+
+// ParseNetworkSelection parses a MultusNetworkAttachAnnotation value in any of the Multus formats:
+// a JSON list of objects with "name", "namespace" and "interface" fields or a comma-separated list
+// of "[<namespace>/]<name>[@<interface>]" with optional spaces around the items. The empty items are skipped.
+// An empty value is an empty selection in the text format.
+func ParseNetworkSelection(value string) (*NetworkSelection, error) {
+	if strings.ContainsAny(value, networkSelectionJSONChars) {
+		return parseNetworkSelectionJSON(value)
+	}
+
+	var selection = &NetworkSelection{Format: NetworkSelectionFormatText}
+
+	for _, item := range strings.Split(value, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+
+		element, err := parseNetworkSelectionText(item)
+		if err != nil {
+			return nil, err
+		}
+
+		selection.Elements = append(selection.Elements, element)
+	}
+
+	return selection, nil
+}
+
+func parseNetworkSelectionJSON(value string) (*NetworkSelection, error) {
+	var raws []json.RawMessage
+
+	if err := json.Unmarshal([]byte(value), &raws); err != nil {
+		return nil, fmt.Errorf("%w: JSON list expected: %s", ErrInvalidNetworkSelection, err.Error())
+	}
+
+	var selection = &NetworkSelection{
+		Format:   NetworkSelectionFormatJSON,
+		Elements: make([]*NetworkSelectionElement, 0, len(raws)),
+	}
+
+	for i, raw := range raws {
+		var element = &NetworkSelectionElement{}
+
+		if err := json.Unmarshal(raw, element); err != nil {
+			return nil, fmt.Errorf("%w: network %d: %s", ErrInvalidNetworkSelection, i, err.Error())
+		}
+
+		if element.Name == "" {
+			return nil, fmt.Errorf("%w: network %d: name is not set", ErrInvalidNetworkSelection, i)
+		}
+
+		element.raw = raw
+
+		selection.Elements = append(selection.Elements, element)
+	}
+
+	return selection, nil
+}
+
+func parseNetworkSelectionText(item string) (*NetworkSelectionElement, error) {
+	var (
+		element = &NetworkSelectionElement{}
+		name    = item
+	)
+
+	if namespace, rest, ok := strings.Cut(name, "/"); ok {
+		element.Namespace = strings.TrimSpace(namespace)
+		name = rest
+
+		if element.Namespace == "" {
+			return nil, fmt.Errorf("%w: empty namespace in %q", ErrInvalidNetworkSelection, item)
+		}
+	}
+
+	if rest, iface, ok := strings.Cut(name, "@"); ok {
+		element.Interface = strings.TrimSpace(iface)
+		name = rest
+
+		if element.Interface == "" {
+			return nil, fmt.Errorf("%w: empty interface in %q", ErrInvalidNetworkSelection, item)
+		}
+	}
+
+	element.Name = strings.TrimSpace(name)
+
+	if element.Name == "" {
+		return nil, fmt.Errorf("%w: empty name in %q", ErrInvalidNetworkSelection, item)
+	}
+
+	if strings.ContainsAny(element.Namespace+element.Name+element.Interface, "/@") {
+		return nil, fmt.Errorf("%w: more than one namespace or interface in %q", ErrInvalidNetworkSelection, item)
+	}
+
+	return element, nil
+}
+
+// Contains reports whether any of the elements selects the NetworkAttachmentDefinition namespace/name.
+func (s *NetworkSelection) Contains(podNamespace, namespace, name string) bool {
+	for _, element := range s.Elements {
+		if element.Selects(podNamespace, namespace, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Add appends the NetworkAttachmentDefinition namespace/name, empty namespace means the Pod's one.
+func (s *NetworkSelection) Add(namespace, name string) {
+	s.Elements = append(s.Elements, &NetworkSelectionElement{Namespace: namespace, Name: name})
+}
+
+// Remove removes all the elements which select the NetworkAttachmentDefinition namespace/name
+// and reports whether any was removed.
+func (s *NetworkSelection) Remove(podNamespace, namespace, name string) bool {
+	var elements = make([]*NetworkSelectionElement, 0, len(s.Elements))
+
+	for _, element := range s.Elements {
+		if !element.Selects(podNamespace, namespace, name) {
+			elements = append(elements, element)
+		}
+	}
+
+	isRemoved := len(elements) != len(s.Elements)
+	s.Elements = elements
+
+	return isRemoved
+}
+
+// String serializes the selection in its format. The parsed JSON elements are kept as they are,
+// the text elements are joined without spaces. An empty selection is an empty string.
+func (s *NetworkSelection) String() string {
+	if len(s.Elements) == 0 {
+		return ""
+	}
+
+	if s.Format != NetworkSelectionFormatJSON {
+		var items = make([]string, 0, len(s.Elements))
+
+		for _, element := range s.Elements {
+			items = append(items, element.String())
+		}
+
+		return strings.Join(items, ",")
+	}
+
+	var raws = make([]json.RawMessage, 0, len(s.Elements))
+
+	for _, element := range s.Elements {
+		raw := element.raw
+		if raw == nil {
+			// The fields are strings, so it can not fail.
+			raw, _ = json.Marshal(element)
+		}
+
+		raws = append(raws, raw)
+	}
+
+	// The elements are valid JSON, so it can not fail.
+	value, _ := json.Marshal(raws)
+
+	return string(value)
+}
+
+func defaultNamespace(namespace, podNamespace string) string {
+	if namespace == "" {
+		return podNamespace
+	}
+
+	return namespace
+}
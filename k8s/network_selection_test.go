@@ -0,0 +1,237 @@
+package k8s
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseNetworkSelection(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		format   NetworkSelectionFormat
+		expected []*NetworkSelectionElement
+	}{
+		{"empty value", "", NetworkSelectionFormatText, nil},
+		{"blank value", "  ", NetworkSelectionFormatText, nil},
+		{"name", "linkerd-cni", NetworkSelectionFormatText,
+			[]*NetworkSelectionElement{{Name: "linkerd-cni"}}},
+		{"namespace and name", "linkerd-cni/linkerd-cni", NetworkSelectionFormatText,
+			[]*NetworkSelectionElement{{Namespace: "linkerd-cni", Name: "linkerd-cni"}}},
+		{"name and interface", "linkerd-cni@eth1", NetworkSelectionFormatText,
+			[]*NetworkSelectionElement{{Name: "linkerd-cni", Interface: "eth1"}}},
+		{"namespace, name and interface", "ns/linkerd-cni@eth1", NetworkSelectionFormatText,
+			[]*NetworkSelectionElement{{Namespace: "ns", Name: "linkerd-cni", Interface: "eth1"}}},
+		{"list with spaces", " macvlan , ns / linkerd-cni @ eth1 ", NetworkSelectionFormatText,
+			[]*NetworkSelectionElement{{Name: "macvlan"}, {Namespace: "ns", Name: "linkerd-cni", Interface: "eth1"}}},
+		{"list with empty items", "macvlan,,linkerd-cni,", NetworkSelectionFormatText,
+			[]*NetworkSelectionElement{{Name: "macvlan"}, {Name: "linkerd-cni"}}},
+		{"empty JSON list", "[]", NetworkSelectionFormatJSON, nil},
+		{"JSON name", `[{"name":"macvlan"}]`, NetworkSelectionFormatJSON,
+			[]*NetworkSelectionElement{{Name: "macvlan"}}},
+		{"JSON with all the fields", `[{"name":"macvlan","namespace":"ns","interface":"eth1","ips":["10.0.0.1/24"]}]`,
+			NetworkSelectionFormatJSON,
+			[]*NetworkSelectionElement{{Namespace: "ns", Name: "macvlan", Interface: "eth1"}}},
+		{"multi-line JSON list", "[\n  {\"name\": \"macvlan\"},\n  {\"name\": \"linkerd-cni\", \"namespace\": \"ns\"}\n]",
+			NetworkSelectionFormatJSON,
+			[]*NetworkSelectionElement{{Name: "macvlan"}, {Namespace: "ns", Name: "linkerd-cni"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selection, err := ParseNetworkSelection(tt.value)
+			if err != nil {
+				t.Fatalf("ParseNetworkSelection() error = %v", err)
+			}
+
+			if selection.Format != tt.format {
+				t.Errorf("ParseNetworkSelection() format = %v, want %v", selection.Format, tt.format)
+			}
+
+			if len(selection.Elements) != len(tt.expected) {
+				t.Fatalf("ParseNetworkSelection() elements = %d, want %d", len(selection.Elements), len(tt.expected))
+			}
+
+			for i, expected := range tt.expected {
+				got := selection.Elements[i]
+
+				if got.Namespace != expected.Namespace || got.Name != expected.Name || got.Interface != expected.Interface {
+					t.Errorf("element %d = %q/%q@%q, want %q/%q@%q", i, got.Namespace, got.Name, got.Interface,
+						expected.Namespace, expected.Name, expected.Interface)
+				}
+			}
+		})
+	}
+}
+
+func TestParseNetworkSelectionInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"empty namespace", "/linkerd-cni"},
+		{"empty name", "ns/"},
+		{"empty name with interface", "@eth1"},
+		{"empty interface", "linkerd-cni@"},
+		{"two namespaces", "a/b/linkerd-cni"},
+		{"two interfaces", "linkerd-cni@eth1@eth2"},
+		{"interface before namespace", "linkerd-cni@eth1/ns"},
+		{"JSON object instead of a list", `{"name":"macvlan"}`},
+		{"JSON string", `"macvlan"`},
+		{"malformed JSON", `[{"name":"macvlan"}`},
+		{"JSON element without name", `[{"namespace":"ns"}]`},
+		{"JSON element with non-string name", `[{"name":1}]`},
+		{"JSON null element", `[null]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseNetworkSelection(tt.value); !errors.Is(err, ErrInvalidNetworkSelection) {
+				t.Errorf("ParseNetworkSelection() error = %v, want %v", err, ErrInvalidNetworkSelection)
+			}
+		})
+	}
+}
+
+// mustParseNetworkSelection parses the value or fails the test.
+func mustParseNetworkSelection(t *testing.T, value string) *NetworkSelection {
+	t.Helper()
+
+	selection, err := ParseNetworkSelection(value)
+	if err != nil {
+		t.Fatalf("ParseNetworkSelection() error = %v", err)
+	}
+
+	return selection
+}
+
+func TestNetworkSelectionContains(t *testing.T) {
+	tests := []struct {
+		name         string
+		value        string
+		podNamespace string
+		namespace    string
+		netAttach    string
+		expected     bool
+	}{
+		{"same name", "linkerd-cni", "app", "", "linkerd-cni", true},
+		{"other name", "macvlan", "app", "", "linkerd-cni", false},
+		{"name with the Pod's namespace", "app/linkerd-cni", "app", "", "linkerd-cni", true},
+		{"name with other namespace", "other/linkerd-cni", "app", "", "linkerd-cni", false},
+		{"name with interface", "linkerd-cni@eth1", "app", "", "linkerd-cni", true},
+		{"name with spaces", "macvlan, linkerd-cni ", "app", "", "linkerd-cni", true},
+		{"reference with the Pod's namespace", "linkerd-cni", "app", "app", "linkerd-cni", true},
+		{"reference with other namespace", "linkerd-cni", "app", "other", "linkerd-cni", false},
+		{"reference with namespace", "other/linkerd-cni@eth1", "app", "other", "linkerd-cni", true},
+		{"JSON name", `[{"name":"linkerd-cni"}]`, "app", "", "linkerd-cni", true},
+		{"JSON name with namespace", `[{"name":"linkerd-cni","namespace":"app"}]`, "app", "", "linkerd-cni", true},
+		{"JSON name with other namespace", `[{"name":"linkerd-cni","namespace":"other"}]`, "app", "", "linkerd-cni", false},
+		{"empty value", "", "app", "", "linkerd-cni", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selection := mustParseNetworkSelection(t, tt.value)
+
+			if got := selection.Contains(tt.podNamespace, tt.namespace, tt.netAttach); got != tt.expected {
+				t.Errorf("Contains() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNetworkSelectionAdd(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		namespace string
+		netAttach string
+		expected  string
+	}{
+		{"to empty value", "", "", "linkerd-cni", "linkerd-cni"},
+		{"with namespace to empty value", "", "ns", "linkerd-cni", "ns/linkerd-cni"},
+		{"to text list", "macvlan@eth1", "", "linkerd-cni", "macvlan@eth1,linkerd-cni"},
+		{"to text list with spaces", "macvlan , ns/sriov", "", "linkerd-cni", "macvlan,ns/sriov,linkerd-cni"},
+		{"to empty JSON list", "[]", "", "linkerd-cni", `[{"name":"linkerd-cni"}]`},
+		{"to JSON list", `[{"name":"macvlan"}]`, "", "linkerd-cni", `[{"name":"macvlan"},{"name":"linkerd-cni"}]`},
+		{"with namespace to JSON list", `[{"name":"macvlan"}]`, "ns", "linkerd-cni",
+			`[{"name":"macvlan"},{"name":"linkerd-cni","namespace":"ns"}]`},
+		{"to JSON list with unknown fields", `[ {"name": "macvlan", "ips": ["10.0.0.1/24"], "mac": "c2:b0:57:49:47:f1"} ]`,
+			"", "linkerd-cni", `[{"name":"macvlan","ips":["10.0.0.1/24"],"mac":"c2:b0:57:49:47:f1"},{"name":"linkerd-cni"}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selection := mustParseNetworkSelection(t, tt.value)
+
+			selection.Add(tt.namespace, tt.netAttach)
+
+			if got := selection.String(); got != tt.expected {
+				t.Errorf("Add() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNetworkSelectionRemove(t *testing.T) {
+	tests := []struct {
+		name            string
+		value           string
+		podNamespace    string
+		namespace       string
+		netAttach       string
+		expectedRemoved bool
+		expected        string
+	}{
+		{"the only network", "linkerd-cni", "app", "", "linkerd-cni", true, ""},
+		{"from text list", "macvlan,linkerd-cni,ns/sriov", "app", "", "linkerd-cni", true, "macvlan,ns/sriov"},
+		{"with the Pod's namespace and interface", "app/linkerd-cni@eth1, macvlan", "app", "", "linkerd-cni",
+			true, "macvlan"},
+		{"all the occurrences", "linkerd-cni,macvlan,linkerd-cni@eth1", "app", "", "linkerd-cni", true, "macvlan"},
+		{"not the other namespace one", "other/linkerd-cni", "app", "", "linkerd-cni", false, "other/linkerd-cni"},
+		{"by reference with namespace", "other/linkerd-cni,linkerd-cni", "app", "other", "linkerd-cni",
+			true, "linkerd-cni"},
+		{"from JSON list", `[{"name":"macvlan","ips":["10.0.0.1/24"]},{"name":"linkerd-cni"}]`, "app", "", "linkerd-cni",
+			true, `[{"name":"macvlan","ips":["10.0.0.1/24"]}]`},
+		{"the only network from JSON list", `[{"name":"linkerd-cni","namespace":"app"}]`, "app", "", "linkerd-cni",
+			true, ""},
+		{"from empty value", "", "app", "", "linkerd-cni", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selection := mustParseNetworkSelection(t, tt.value)
+
+			if removed := selection.Remove(tt.podNamespace, tt.namespace, tt.netAttach); removed != tt.expectedRemoved {
+				t.Errorf("Remove() = %v, want %v", removed, tt.expectedRemoved)
+			}
+
+			if got := selection.String(); got != tt.expected {
+				t.Errorf("Remove() selection = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNetworkSelectionString(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{"empty value", "", ""},
+		{"text list", "a,ns/b,c@eth1,ns/d@eth2", "a,ns/b,c@eth1,ns/d@eth2"},
+		{"text list with spaces and empty items", " a , ns/b ,, ", "a,ns/b"},
+		{"JSON list", `[{"name":"a","interface":"eth1"},{"name":"b","namespace":"ns"}]`,
+			`[{"name":"a","interface":"eth1"},{"name":"b","namespace":"ns"}]`},
+		{"empty JSON list", "[]", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mustParseNetworkSelection(t, tt.value).String(); got != tt.expected {
+				t.Errorf("String() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package k8s
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidUIDRange is returned when a namespace UID range annotation does not conform
+// to {{ first UID }}/{{ length }} format.
+var ErrInvalidUIDRange = errors.New("UID range does not conform to {{ first ID }}/{{ range }} format")
+
+// ProxyUIDFromRange returns Linkerd proxy UID for a namespace UID range annotation value
+// like "10000000/2000": the first UID of the range plus the offset.
+// The webhook and the controller use it, so the Pods' proxy UID and the NetworkAttachmentDefinition agree.
+func ProxyUIDFromRange(uidRange string, offset int) (int, error) {
+	splIDRange := strings.Split(uidRange, "/")
+	if len(splIDRange) != 2 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidUIDRange, uidRange)
+	}
+
+	uid, err := strconv.Atoi(splIDRange[0])
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q, the first ID is not an integer: %s", ErrInvalidUIDRange, uidRange, err.Error())
+	}
+
+	return uid + offset, nil
+}
@@ -20,22 +20,32 @@ import (
 	"flag"
 	"os"
 	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	whapiv1 "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/api/v1"
+	multusv1alpha1 "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/api/v1alpha1"
 	"github.com/ErmakovDmitriy/linkerd-multus-attach-operator/controllers"
 	"github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
+	"github.com/ErmakovDmitriy/linkerd-multus-attach-operator/settings"
 	netattachv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
 	//+kubebuilder:scaffold:imports
 )
@@ -48,6 +58,7 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(netattachv1.AddToScheme(scheme))
+	utilruntime.Must(multusv1alpha1.AddToScheme(scheme))
 
 	//+kubebuilder:scaffold:scheme
 }
@@ -64,6 +75,36 @@ func main() {
 
 		allowedUIDAnnotationName string
 		linkerdProxyUIDOffset    int
+
+		operatorInstance  string
+		rawAdoptionPolicy string
+		configName        string
+		attachLabel       string
+		netAttachName     string
+
+		driftCheckInterval time.Duration
+		enableStartupGC    bool
+		startupGCDryRun    bool
+		rawMode            string
+		forceOwnership     bool
+		inUseProtection    bool
+
+		cniVersion              string
+		rawCNISupportedVersions string
+
+		cniConfigSourceKind string
+		cniConfigName       string
+		cniConfigKey        string
+		cniConfigFile       string
+		extraCNINamespaces  string
+
+		enableCNIDiscovery bool
+		primaryCNINetDirs  string
+
+		watchNamespaces        string
+		watchNamespaceSelector string
+
+		enableWorkloadWebhook bool
 	)
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
@@ -78,6 +119,71 @@ func main() {
 		k8s.NamespaceAllowedUIDRangeAnnotationDefault, "Namespace annotation name which should contain allowed container UID range in {{ first UID }}/{{ length }} format")
 	flag.IntVar(&linkerdProxyUIDOffset, "linkerd-proxy-uid-offset", k8s.LinkerdProxyUIDDefaultOffset, "Offset to add to the first allowed UID in a namespace to generate Linkerd proxy UID")
 
+	flag.StringVar(&operatorInstance, "operator-instance", k8s.OperatorInstanceDefault,
+		"Operator instance name which is put in the "+k8s.OperatorInstanceLabel+" label of managed NetworkAttachmentDefinitions")
+	flag.StringVar(&rawAdoptionPolicy, "nad-adoption-policy", string(controllers.AdoptionPolicyAdopt),
+		"What to do with existing NetworkAttachmentDefinitions which are not managed by the operator: adopt, ignore or fail")
+
+	flag.StringVar(&configName, "config-name", multusv1alpha1.LinkerdMultusConfigNameDefault,
+		"Name of the cluster-scoped LinkerdMultusConfig resource which overrides the flag values")
+
+	flag.StringVar(&attachLabel, "attach-label", k8s.MultusAttachLabelDefault,
+		"Namespace and Pod label which requests Linkerd CNI attachment as the "+k8s.MultusAttachAnnotation+
+			" annotation does, the annotation wins if both are set. Empty value disables the label")
+
+	flag.StringVar(&netAttachName, "nad-name", k8s.MultusNetworkAttachmentDefinitionName,
+		"Name of the NetworkAttachmentDefinitions, a namespace overrides it with the "+
+			k8s.NetworkAttachmentDefinitionNameAnnotation+" annotation")
+
+	flag.DurationVar(&driftCheckInterval, "drift-check-interval", 10*time.Minute,
+		"Period of the full comparison of the desired and the actual NetworkAttachmentDefinitions in all namespaces, 0 disables it")
+
+	flag.BoolVar(&enableStartupGC, "startup-gc", true,
+		"Delete managed NetworkAttachmentDefinitions in namespaces which do not require them after the leader election")
+	flag.BoolVar(&startupGCDryRun, "startup-gc-dry-run", false,
+		"Only report the NetworkAttachmentDefinitions the startup garbage collection would delete")
+
+	flag.StringVar(&rawMode, "mode", string(multusv1alpha1.ModeEnforce),
+		"Enforce to apply the changes or Audit to only report the changes the controller and the webhook would make")
+
+	flag.BoolVar(&forceOwnership, "nad-force-ownership", false,
+		"Take over the NetworkAttachmentDefinition fields managed by other server-side apply field managers instead of failing")
+	flag.BoolVar(&inUseProtection, "nad-in-use-protection", false,
+		"Hold the deletion of the managed NetworkAttachmentDefinitions while Pods use them with the "+
+			k8s.NetAttachFinalizer+" finalizer, disabling it removes the finalizer")
+
+	flag.StringVar(&cniVersion, "cni-version", "",
+		"CNI specification version of the NetworkAttachmentDefinitions, empty value keeps the Linkerd-CNI configuration version")
+	flag.StringVar(&rawCNISupportedVersions, "cni-supported-versions", strings.Join(controllers.LinkerdCNISupportedVersions, ","),
+		"Comma-separated CNI specification versions the installed Linkerd-CNI supports")
+
+	flag.StringVar(&cniConfigSourceKind, "cni-config-source", controllers.CNIConfigSourceConfigMap,
+		"Source of the Linkerd-CNI configuration: configmap or secret in the Linkerd-CNI namespace, or file")
+	flag.StringVar(&cniConfigName, "cni-config-name", k8s.LinkerdCNIConfigMapName,
+		"Name of the ConfigMap or Secret which contains the Linkerd-CNI configuration")
+	flag.StringVar(&cniConfigKey, "cni-config-key", k8s.LinkerdCNIConfigMapKey,
+		"Key of the ConfigMap or Secret which contains the Linkerd-CNI configuration")
+	flag.StringVar(&cniConfigFile, "cni-config-file", "",
+		"Path of the local file which contains the Linkerd-CNI configuration of all the meshes, used by the file source")
+	flag.StringVar(&extraCNINamespaces, "extra-cni-namespaces", "",
+		"Comma-separated Linkerd-CNI namespaces of the LinkerdMultusConfig meshes besides -cni-namespace, "+
+			"the secret source reads the Secret only in these namespaces")
+
+	flag.BoolVar(&enableCNIDiscovery, "cni-discovery", false,
+		"Find the Linkerd-CNI DaemonSet and ConfigMap of every mesh in any namespace and refuse to render "+
+			"NetworkAttachmentDefinitions for Linkerd-CNI chained into the primary CNI configuration")
+	flag.StringVar(&primaryCNINetDirs, "primary-cni-net-dirs", k8s.PrimaryCNINetDirDefault,
+		"Comma-separated host directories of the primary CNI configuration, Linkerd-CNI installed there is chained")
+
+	flag.StringVar(&watchNamespaces, "watch-namespaces", "",
+		"Comma-separated namespaces the operator is restricted to, empty value means all the namespaces")
+	flag.StringVar(&watchNamespaceSelector, "watch-namespace-selector", "",
+		"Label selector of the namespaces the operator is restricted to, empty value means all the namespaces")
+
+	flag.BoolVar(&enableWorkloadWebhook, "workload-webhook", false,
+		"Serve the webhook which adds the Multus annotation to the Pod templates of Deployments, StatefulSets, "+
+			"DaemonSets, Jobs and CronJobs")
+
 	opts := zap.Options{
 		Development: true,
 	}
@@ -90,6 +196,52 @@ func main() {
 	// The quotes being then embedded in the Linkerd-CNI configuration cause its failure so they must be removed.
 	cniKubeconfigFilePath := strings.Trim(rawCNIKubeconfigFilePath, `\"`)
 
+	adoptionPolicy, err := controllers.ParseAdoptionPolicy(rawAdoptionPolicy)
+	if err != nil {
+		setupLog.Error(err, "invalid flag value", "flag", "nad-adoption-policy")
+		os.Exit(1)
+	}
+
+	mode, err := settings.ParseMode(rawMode)
+	if err != nil {
+		setupLog.Error(err, "invalid flag value", "flag", "mode")
+		os.Exit(1)
+	}
+
+	cniSupportedVersions, err := settings.ParseCNIVersions(rawCNISupportedVersions)
+	if err != nil {
+		setupLog.Error(err, "invalid flag value", "flag", "cni-supported-versions")
+		os.Exit(1)
+	}
+
+	if _, err := settings.ParseCNIVersions(cniVersion); err != nil {
+		setupLog.Error(err, "invalid flag value", "flag", "cni-version")
+		os.Exit(1)
+	}
+
+	extraNamespaces, err := settings.ParseNamespaces(extraCNINamespaces)
+	if err != nil {
+		setupLog.Error(err, "invalid flag value", "flag", "extra-cni-namespaces")
+		os.Exit(1)
+	}
+
+	cniNamespaces := settings.AppendMissingNamespaces([]string{cniNamespace}, extraNamespaces...)
+
+	namespaceScope, err := settings.ParseNamespaceScope(watchNamespaces, watchNamespaceSelector)
+	if err != nil {
+		setupLog.Error(err, "invalid flag value", "flag", "watch-namespaces")
+		os.Exit(1)
+	}
+
+	// With the namespace list only the listed namespaces and the Linkerd ones known on start are cached.
+	cachedNamespaces := namespaceScope.CachedNamespaces(append(cniNamespaces, linkerdNamespace)...)
+
+	newCache, err := newCacheFunc(namespaceScope, cachedNamespaces)
+	if err != nil {
+		setupLog.Error(err, "unable to set up the namespace scope cache")
+		os.Exit(1)
+	}
+
 	setupLog.Info("Starting controller with parameters",
 		"metrics-bind-addr", metricsAddr,
 		"health-probe-bind-address", probeAddr,
@@ -97,7 +249,30 @@ func main() {
 		"cni-namespace", cniNamespace,
 		"cni-kubeconfig", cniKubeconfigFilePath,
 		"linkerd-namespace", linkerdNamespace,
-		"webhook-port", webHookPort)
+		"webhook-port", webHookPort,
+		"operator-instance", operatorInstance,
+		"nad-adoption-policy", adoptionPolicy,
+		"config-name", configName,
+		"attach-label", attachLabel,
+		"nad-name", netAttachName,
+		"drift-check-interval", driftCheckInterval,
+		"startup-gc", enableStartupGC,
+		"startup-gc-dry-run", startupGCDryRun,
+		"mode", mode,
+		"nad-force-ownership", forceOwnership,
+		"nad-in-use-protection", inUseProtection,
+		"cni-version", cniVersion,
+		"cni-supported-versions", cniSupportedVersions,
+		"cni-config-source", cniConfigSourceKind,
+		"cni-config-name", cniConfigName,
+		"cni-config-key", cniConfigKey,
+		"cni-config-file", cniConfigFile,
+		"extra-cni-namespaces", extraCNINamespaces,
+		"cni-discovery", enableCNIDiscovery,
+		"primary-cni-net-dirs", primaryCNINetDirs,
+		"watch-namespaces", watchNamespaces,
+		"watch-namespace-selector", watchNamespaceSelector,
+		"workload-webhook", enableWorkloadWebhook)
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
@@ -106,24 +281,121 @@ func main() {
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "1a7407a5.multus.linkerd.io",
+		NewCache:               newCache,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	if err = (&controllers.NamespaceReconciler{
-		Client:                       mgr.GetClient(),
-		Scheme:                       mgr.GetScheme(),
-		LinkerdControlPlaneNamespace: linkerdNamespace,
-		LinkerdCNINamespace:          cniNamespace,
-		LinkerdCNIKubeconfigPath:     cniKubeconfigFilePath,
-	}).SetupWithManager(mgr); err != nil {
+	cniConfigSource, err := controllers.NewCNIConfigSource(mgr, cniConfigSourceKind,
+		cniConfigName, cniConfigKey, cniConfigFile, cniNamespaces, cachedNamespaces)
+	if err != nil {
+		setupLog.Error(err, "invalid flag value", "flag", "cni-config-source")
+		os.Exit(1)
+	}
+
+	var cniDiscovery *controllers.CNIDiscovery
+
+	if enableCNIDiscovery {
+		cniDiscovery = &controllers.CNIDiscovery{
+			Client:         mgr.GetClient(),
+			PrimaryNetDirs: strings.Split(primaryCNINetDirs, ","),
+		}
+	}
+
+	// The file source polls the file to notify the controllers about its changes,
+	// the Secret source runs its Secret cache.
+	if runnable, ok := cniConfigSource.(manager.Runnable); ok {
+		if err = mgr.Add(runnable); err != nil {
+			setupLog.Error(err, "unable to add Linkerd-CNI configuration source")
+			os.Exit(1)
+		}
+	}
+
+	// The flags are the defaults which are overridden by the LinkerdMultusConfig resource.
+	settingsLoader := &settings.Loader{
+		Client:     mgr.GetClient(),
+		ConfigName: configName,
+		Defaults: settings.Settings{
+			CNINamespace:                    cniNamespace,
+			CNIKubeconfigPath:               cniKubeconfigFilePath,
+			LinkerdNamespace:                linkerdNamespace,
+			NamespaceUIDRangeAnnotation:     allowedUIDAnnotationName,
+			LinkerdProxyUIDOffset:           linkerdProxyUIDOffset,
+			AttachLabel:                     attachLabel,
+			NetworkAttachmentDefinitionName: netAttachName,
+			Mode:                            mode,
+			CNIVersion:                      cniVersion,
+			CNISupportedVersions:            cniSupportedVersions,
+		},
+	}
+
+	// The drift detector enqueues the drifted namespaces to the Namespace controller.
+	var driftEvents chan event.GenericEvent
+
+	if driftCheckInterval > 0 {
+		driftEvents = make(chan event.GenericEvent)
+	}
+
+	namespaceReconciler := &controllers.NamespaceReconciler{
+		Client:           mgr.GetClient(),
+		Scheme:           mgr.GetScheme(),
+		Settings:         settingsLoader,
+		OperatorInstance: operatorInstance,
+		AdoptionPolicy:   adoptionPolicy,
+		ForceOwnership:   forceOwnership,
+		InUseProtection:  inUseProtection,
+		APIReader:        mgr.GetAPIReader(),
+		Recorder:         mgr.GetEventRecorderFor("linkerd-multus-attach-operator"),
+		CNIConfigSource:  cniConfigSource,
+		CNIDiscovery:     cniDiscovery,
+		DriftEvents:      driftEvents,
+	}
+
+	if err = namespaceReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Namespace")
 		os.Exit(1)
 	}
 
-	whapiv1.SetupWebhookWithManager(mgr, linkerdNamespace, allowedUIDAnnotationName, linkerdProxyUIDOffset)
+	if enableStartupGC {
+		if err = mgr.Add(&controllers.GarbageCollector{
+			Client:     mgr.GetClient(),
+			Reconciler: namespaceReconciler,
+			DryRun:     startupGCDryRun,
+		}); err != nil {
+			setupLog.Error(err, "unable to add garbage collector")
+			os.Exit(1)
+		}
+	}
+
+	if driftCheckInterval > 0 {
+		if err = mgr.Add(&controllers.DriftDetector{
+			Client:     mgr.GetClient(),
+			Reconciler: namespaceReconciler,
+			Events:     driftEvents,
+			Interval:   driftCheckInterval,
+		}); err != nil {
+			setupLog.Error(err, "unable to add drift detector")
+			os.Exit(1)
+		}
+	}
+
+	if err = (&controllers.LinkerdMultusConfigReconciler{
+		Client:          mgr.GetClient(),
+		Settings:        settingsLoader,
+		CNIConfigSource: cniConfigSource,
+		CNIDiscovery:    cniDiscovery,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "LinkerdMultusConfig")
+		os.Exit(1)
+	}
+
+	whapiv1.SetupWebhookWithManager(mgr, settingsLoader, namespaceScope)
+
+	if enableWorkloadWebhook {
+		whapiv1.SetupWorkloadWebhookWithManager(mgr, settingsLoader, namespaceScope)
+	}
 
 	//+kubebuilder:scaffold:builder
 
@@ -142,3 +414,38 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// newCacheFunc returns the manager's cache builder. Only the Linkerd CNI DaemonSets are cached for the discovery.
+// If the operator is restricted to a namespace scope, only the Namespaces in the scope are cached
+// and, for an explicit namespace list, the namespaced objects are cached only in the cachedNamespaces:
+// the listed namespaces and the Linkerd ones, so the operator does not need the cluster-wide access to them.
+func newCacheFunc(scope *settings.NamespaceScope, cachedNamespaces []string) (cache.NewCacheFunc, error) {
+	var options = cache.Options{
+		SelectorsByObject: cache.SelectorsByObject{
+			&appsv1.DaemonSet{}: {
+				Label: labels.SelectorFromSet(labels.Set{k8s.LinkerdCNIDaemonSetLabel: k8s.LinkerdCNIDaemonSetLabelValue}),
+			},
+		},
+	}
+
+	if scope == nil {
+		return cache.BuilderWithOptions(options), nil
+	}
+
+	namespaceSelector, err := scope.LabelSelector()
+	if err != nil {
+		return nil, err
+	}
+
+	options.SelectorsByObject[&corev1.Namespace{}] = cache.ObjectSelector{Label: namespaceSelector}
+
+	if len(cachedNamespaces) == 0 {
+		return cache.BuilderWithOptions(options), nil
+	}
+
+	return func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+		opts.SelectorsByObject = options.SelectorsByObject
+
+		return cache.MultiNamespacedCacheBuilder(cachedNamespaces)(config, opts)
+	}, nil
+}
@@ -0,0 +1,159 @@
+package settings
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// NamespaceScope restricts the operator to a set of namespaces: an explicit namespace list,
+// a label selector or both. A nil scope is the whole cluster.
+// The scope is fixed on start as the manager's cache and RBAC depend on it.
+type NamespaceScope struct {
+	// Namespaces is the explicit namespace list, empty list means any namespace.
+	Namespaces []string
+	// Selector selects the namespaces by their labels, nil means any namespace.
+	Selector labels.Selector
+}
+
+// ParseNamespaceScope parses the comma-separated namespace list and the label selector.
+// Returns nil if both are empty.
+func ParseNamespaceScope(rawNamespaces, rawSelector string) (*NamespaceScope, error) {
+	namespaces, err := ParseNamespaces(rawNamespaces)
+	if err != nil {
+		return nil, err
+	}
+
+	var scope = &NamespaceScope{Namespaces: namespaces}
+
+	if strings.TrimSpace(rawSelector) != "" {
+		selector, err := labels.Parse(rawSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace label selector %q: %w", rawSelector, err)
+		}
+
+		scope.Selector = selector
+	}
+
+	if len(scope.Namespaces) == 0 && scope.Selector == nil {
+		return nil, nil
+	}
+
+	return scope, nil
+}
+
+// ParseNamespaces parses a comma-separated namespace list and checks the names.
+// The empty items are skipped.
+func ParseNamespaces(value string) ([]string, error) {
+	var namespaces []string
+
+	for _, namespace := range strings.Split(value, ",") {
+		namespace = strings.TrimSpace(namespace)
+		if namespace == "" {
+			continue
+		}
+
+		if errs := validation.IsDNS1123Label(namespace); len(errs) != 0 {
+			return nil, fmt.Errorf("invalid namespace name %q: %s", namespace, strings.Join(errs, ", "))
+		}
+
+		namespaces = append(namespaces, namespace)
+	}
+
+	return namespaces, nil
+}
+
+// ContainsName reports whether a namespace is in the explicit namespace list.
+// The label selector is not checked as it needs the Namespace object.
+func (s *NamespaceScope) ContainsName(name string) bool {
+	if s == nil || len(s.Namespaces) == 0 {
+		return true
+	}
+
+	for _, namespace := range s.Namespaces {
+		if namespace == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Contains reports whether a namespace is in the scope.
+func (s *NamespaceScope) Contains(ns *corev1.Namespace) bool {
+	if s == nil {
+		return true
+	}
+
+	if !s.ContainsName(ns.Name) {
+		return false
+	}
+
+	return s.Selector == nil || s.Selector.Matches(labels.Set(ns.GetLabels()))
+}
+
+// CachedNamespaces returns the namespaces the namespaced objects are cached in with the explicit namespace list:
+// the listed namespaces and the system namespaces the operator reads, e.g. the Linkerd CNI namespaces.
+// Returns nil, if the objects are cached in all the namespaces.
+func (s *NamespaceScope) CachedNamespaces(systemNamespaces ...string) []string {
+	if s == nil || len(s.Namespaces) == 0 {
+		return nil
+	}
+
+	return AppendMissingNamespaces(append([]string{}, s.Namespaces...), systemNamespaces...)
+}
+
+// AppendMissingNamespaces appends the namespaces which are not in the list yet.
+func AppendMissingNamespaces(namespaces []string, others ...string) []string {
+	for _, other := range others {
+		var isFound bool
+
+		for _, namespace := range namespaces {
+			if namespace == other {
+				isFound = true
+
+				break
+			}
+		}
+
+		if !isFound {
+			namespaces = append(namespaces, other)
+		}
+	}
+
+	return namespaces
+}
+
+// HasSelector reports whether the scope selects the namespaces by their labels.
+func (s *NamespaceScope) HasSelector() bool {
+	return s != nil && s.Selector != nil
+}
+
+// LabelSelector returns the label selector of the Namespace objects in the scope.
+// The explicit namespace list is matched by the LabelMetadataName label Kubernetes sets on every namespace.
+func (s *NamespaceScope) LabelSelector() (labels.Selector, error) {
+	var selector = labels.Everything()
+
+	if s == nil {
+		return selector, nil
+	}
+
+	if s.Selector != nil {
+		selector = s.Selector
+	}
+
+	if len(s.Namespaces) != 0 {
+		requirement, err := labels.NewRequirement(corev1.LabelMetadataName, selection.In, s.Namespaces)
+		if err != nil {
+			return nil, fmt.Errorf("can not build namespace name selector: %w", err)
+		}
+
+		selector = selector.Add(*requirement)
+	}
+
+	return selector, nil
+}
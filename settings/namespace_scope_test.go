@@ -0,0 +1,149 @@
+package settings
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseNamespaces(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expected    []string
+		expectedErr bool
+	}{
+		{name: "empty value"},
+		{name: "namespaces", value: "app, web,,", expected: []string{"app", "web"}},
+		{name: "invalid name", value: "app,Web", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseNamespaces(tt.value)
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("ParseNamespaces() error = %v, want error %v", err, tt.expectedErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ParseNamespaces() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseNamespaceScope(t *testing.T) {
+	tests := []struct {
+		name        string
+		namespaces  string
+		selector    string
+		expectedNil bool
+		expectedErr bool
+	}{
+		{name: "whole cluster", selector: " ", expectedNil: true},
+		{name: "namespaces", namespaces: "app"},
+		{name: "selector", selector: "team=a"},
+		{name: "invalid namespace", namespaces: "App", expectedErr: true},
+		{name: "invalid selector", selector: "team in (a", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope, err := ParseNamespaceScope(tt.namespaces, tt.selector)
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("ParseNamespaceScope() error = %v, want error %v", err, tt.expectedErr)
+			}
+
+			if !tt.expectedErr && (scope == nil) != tt.expectedNil {
+				t.Errorf("ParseNamespaceScope() = %+v, want nil %v", scope, tt.expectedNil)
+			}
+		})
+	}
+}
+
+func TestNamespaceScopeContains(t *testing.T) {
+	mustParse := func(namespaces, selector string) *NamespaceScope {
+		scope, err := ParseNamespaceScope(namespaces, selector)
+		if err != nil {
+			t.Fatalf("ParseNamespaceScope() error = %v", err)
+		}
+
+		return scope
+	}
+
+	tests := []struct {
+		name      string
+		scope     *NamespaceScope
+		namespace string
+		labels    map[string]string
+		expected  bool
+	}{
+		{"whole cluster", nil, "app", nil, true},
+		{"listed namespace", mustParse("app,web", ""), "web", nil, true},
+		{"not listed namespace", mustParse("app,web", ""), "db", nil, false},
+		{"selected namespace", mustParse("", "team=a"), "app", map[string]string{"team": "a"}, true},
+		{"not selected namespace", mustParse("", "team=a"), "app", map[string]string{"team": "b"}, false},
+		{"listed but not selected namespace", mustParse("app", "team=a"), "app", nil, false},
+		{"selected but not listed namespace", mustParse("app", "team=a"), "web", map[string]string{"team": "a"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: tt.namespace, Labels: tt.labels}}
+
+			if got := tt.scope.Contains(ns); got != tt.expected {
+				t.Errorf("Contains() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNamespaceScopeCachedNamespaces(t *testing.T) {
+	tests := []struct {
+		name     string
+		scope    *NamespaceScope
+		expected []string
+	}{
+		{"whole cluster", nil, nil},
+		{"selector only", &NamespaceScope{}, nil},
+		{"namespaces and the missing system namespaces", &NamespaceScope{Namespaces: []string{"app", "linkerd"}},
+			[]string{"app", "linkerd", "linkerd-cni"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.scope.CachedNamespaces("linkerd", "linkerd-cni")
+
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("CachedNamespaces() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNamespaceScopeLabelSelector(t *testing.T) {
+	tests := []struct {
+		name     string
+		scope    *NamespaceScope
+		expected string
+	}{
+		{"whole cluster", nil, ""},
+		{"namespaces", &NamespaceScope{Namespaces: []string{"web", "app"}},
+			corev1.LabelMetadataName + " in (app,web)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.scope.LabelSelector()
+			if err != nil {
+				t.Fatalf("LabelSelector() error = %v", err)
+			}
+
+			if got.String() != tt.expected {
+				t.Errorf("LabelSelector() = %q, want %q", got.String(), tt.expected)
+			}
+		})
+	}
+}
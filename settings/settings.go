@@ -0,0 +1,374 @@
+// Package settings resolves the operator configuration from the command-line flags
+// and the cluster-scoped LinkerdMultusConfig resource.
+// The resource is read on every use, so its changes are applied without a restart.
+package settings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/containernetworking/cni/pkg/version"
+
+	multusv1alpha1 "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/api/v1alpha1"
+	k8s "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
+)
+
+// ErrInvalidNetworkAttachmentDefinitionName is returned when a NetworkAttachmentDefinition name
+// or reference given by an annotation is not valid.
+var ErrInvalidNetworkAttachmentDefinitionName = errors.New("invalid NetworkAttachmentDefinition name")
+
+// Settings is the effective operator configuration.
+type Settings struct {
+	// CNINamespace is the namespace in which Linkerd CNI is installed.
+	CNINamespace string
+	// CNIKubeconfigPath is the path on Kubernetes hosts where Linkerd CNI DaemonSet Pods put Kubeconfig.
+	CNIKubeconfigPath string
+	// LinkerdNamespace is the namespace in which Linkerd control plane is installed.
+	LinkerdNamespace string
+	// NamespaceUIDRangeAnnotation is the Namespace annotation which contains allowed container UID range.
+	NamespaceUIDRangeAnnotation string
+	// LinkerdProxyUIDOffset is added to the first allowed UID in a namespace to generate Linkerd proxy UID.
+	LinkerdProxyUIDOffset int
+	// AttachLabel is the Namespace and Pod label which requests the NetworkAttachmentDefinition
+	// as the MultusAttachAnnotation does. Empty value disables the label.
+	AttachLabel string
+	// NetworkAttachmentDefinitionName is the default name of the NetworkAttachmentDefinitions.
+	NetworkAttachmentDefinitionName string
+	// Mode is Enforce to apply the changes or Audit to only report them.
+	Mode multusv1alpha1.Mode
+	// CNIVersion is the CNI specification version of the NetworkAttachmentDefinitions.
+	// Empty value keeps the version of the Linkerd CNI configuration.
+	CNIVersion string
+	// CNISupportedVersions are the CNI specification versions Linkerd CNI supports.
+	CNISupportedVersions []string
+	// Chain is the CNI plugin chain the NetworkAttachmentDefinitions are rendered as.
+	// Nil means that the NetworkAttachmentDefinitions contain only Linkerd CNI.
+	Chain *Chain
+	// Meshes are the Linkerd control planes in the cluster. If empty, the only mesh is
+	// defined by the LinkerdNamespace, CNINamespace and CNIKubeconfigPath fields.
+	Meshes []Mesh
+}
+
+// IsAudit checks if the operator must only report the changes it would make.
+func (s Settings) IsAudit() bool {
+	return s.Mode == multusv1alpha1.ModeAudit
+}
+
+// ParseMode converts a string to Mode and checks that the value is known.
+func ParseMode(value string) (multusv1alpha1.Mode, error) {
+	switch mode := multusv1alpha1.Mode(value); mode {
+	case multusv1alpha1.ModeEnforce, multusv1alpha1.ModeAudit:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown operator mode %q, expected one of: %s, %s",
+			value, multusv1alpha1.ModeEnforce, multusv1alpha1.ModeAudit)
+	}
+}
+
+// ParseCNIVersions converts a comma-separated list to CNI specification versions and checks
+// that the versions are valid. Empty value means no versions.
+func ParseCNIVersions(value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var versions []string
+
+	for _, cniVersion := range strings.Split(value, ",") {
+		cniVersion = strings.TrimSpace(cniVersion)
+
+		if _, _, _, err := version.ParseVersion(cniVersion); err != nil {
+			return nil, fmt.Errorf("invalid CNI version %q: %w", cniVersion, err)
+		}
+
+		versions = append(versions, cniVersion)
+	}
+
+	return versions, nil
+}
+
+// Chain is a CNI plugin chain in which Linkerd CNI is placed.
+type Chain struct {
+	// Plugins are the CNI plugin configurations chained with Linkerd CNI.
+	Plugins []json.RawMessage
+	// LinkerdPosition is the index of Linkerd CNI in the chain, negative value means the end of the chain.
+	LinkerdPosition int
+}
+
+// Mesh is a Linkerd control plane with its Linkerd CNI installation.
+type Mesh struct {
+	// LinkerdNamespace is the namespace of the Linkerd control plane.
+	// It identifies the mesh.
+	LinkerdNamespace string
+	// CNINamespace is the namespace in which the mesh's Linkerd CNI is installed.
+	CNINamespace string
+	// CNIKubeconfigPath is the path on Kubernetes hosts where the mesh's Linkerd CNI puts Kubeconfig.
+	CNIKubeconfigPath string
+	// NetworkAttachmentDefinitionName is the name of the mesh's NetworkAttachmentDefinitions.
+	NetworkAttachmentDefinitionName string
+}
+
+// GetMeshes returns the configured meshes or the default one.
+// The first mesh is the default mesh.
+func (s Settings) GetMeshes() []Mesh {
+	if len(s.Meshes) != 0 {
+		return s.Meshes
+	}
+
+	return []Mesh{
+		{
+			LinkerdNamespace:                s.LinkerdNamespace,
+			CNINamespace:                    s.CNINamespace,
+			CNIKubeconfigPath:               s.CNIKubeconfigPath,
+			NetworkAttachmentDefinitionName: s.defaultNetworkAttachmentDefinitionName(),
+		},
+	}
+}
+
+// defaultNetworkAttachmentDefinitionName returns the configured NetworkAttachmentDefinition name
+// or MultusNetworkAttachmentDefinitionName, if it is not configured.
+func (s Settings) defaultNetworkAttachmentDefinitionName() string {
+	if s.NetworkAttachmentDefinitionName != "" {
+		return s.NetworkAttachmentDefinitionName
+	}
+
+	return k8s.MultusNetworkAttachmentDefinitionName
+}
+
+// NetworkAttachmentDefinitionNameFor returns the name of the mesh's NetworkAttachmentDefinition
+// in a namespace. The NetworkAttachmentDefinitionNameAnnotation namespace annotation overrides the name.
+// Returns an error, if the annotation's value is not a valid object name.
+func (m *Mesh) NetworkAttachmentDefinitionNameFor(ns *corev1.Namespace) (string, error) {
+	name, ok := ns.Annotations[k8s.NetworkAttachmentDefinitionNameAnnotation]
+	if !ok || name == "" {
+		return m.NetworkAttachmentDefinitionName, nil
+	}
+
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) != 0 {
+		return "", fmt.Errorf("%w: %s annotation value %q: %s", ErrInvalidNetworkAttachmentDefinitionName,
+			k8s.NetworkAttachmentDefinitionNameAnnotation, name, strings.Join(errs, ", "))
+	}
+
+	return name, nil
+}
+
+// ParseNetworkAttachmentDefinitionReference validates a NetworkAttachmentDefinition reference
+// in "{{ namespace }}/{{ name }}" or "{{ name }}" format.
+func ParseNetworkAttachmentDefinitionReference(reference string) (namespace, name string, err error) {
+	name = reference
+
+	if parts := strings.SplitN(reference, "/", 2); len(parts) == 2 {
+		namespace, name = parts[0], parts[1]
+
+		if errs := validation.IsDNS1123Label(namespace); len(errs) != 0 {
+			return "", "", fmt.Errorf("%w: namespace in %q: %s", ErrInvalidNetworkAttachmentDefinitionName,
+				reference, strings.Join(errs, ", "))
+		}
+	}
+
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) != 0 {
+		return "", "", fmt.Errorf("%w: name in %q: %s", ErrInvalidNetworkAttachmentDefinitionName,
+			reference, strings.Join(errs, ", "))
+	}
+
+	return namespace, name, nil
+}
+
+// MeshByLinkerdNamespace returns the mesh with the given control plane namespace
+// or nil, if there is no such mesh.
+func (s Settings) MeshByLinkerdNamespace(linkerdNamespace string) *Mesh {
+	meshes := s.GetMeshes()
+
+	for i := range meshes {
+		if meshes[i].LinkerdNamespace == linkerdNamespace {
+			return &meshes[i]
+		}
+	}
+
+	return nil
+}
+
+// meshByReference returns the mesh referenced by the LinkerdControlPlaneNamespaceLabel value.
+// Empty reference means the default mesh. Returns nil, if the referenced mesh does not exist.
+func (s Settings) meshByReference(reference string) *Mesh {
+	if reference == "" {
+		return &s.GetMeshes()[0]
+	}
+
+	return s.MeshByLinkerdNamespace(reference)
+}
+
+// MeshForNamespace returns the mesh of a namespace. A control plane namespace belongs to its mesh,
+// other namespaces select a mesh by the LinkerdControlPlaneNamespaceLabel label or annotation.
+// Returns nil, if the namespace references an unknown mesh.
+func (s Settings) MeshForNamespace(ns *corev1.Namespace) *Mesh {
+	if mesh := s.MeshByLinkerdNamespace(ns.Name); mesh != nil {
+		return mesh
+	}
+
+	return s.meshByReference(ControlPlaneNamespaceReference(ns.Labels, ns.Annotations))
+}
+
+// ControlPlaneNamespaceReference returns the LinkerdControlPlaneNamespaceLabel label value
+// or, if the label is not set, the annotation with the same name.
+func ControlPlaneNamespaceReference(labels, annotations map[string]string) string {
+	if reference := labels[k8s.LinkerdControlPlaneNamespaceLabel]; reference != "" {
+		return reference
+	}
+
+	return annotations[k8s.LinkerdControlPlaneNamespaceLabel]
+}
+
+// Merge returns a copy of the settings with the fields overridden by
+// the not empty LinkerdMultusConfig spec fields.
+func (s Settings) Merge(spec *multusv1alpha1.LinkerdMultusConfigSpec) Settings {
+	if spec == nil {
+		return s
+	}
+
+	if spec.CNINamespace != "" {
+		s.CNINamespace = spec.CNINamespace
+	}
+
+	if spec.CNIKubeconfigPath != "" {
+		s.CNIKubeconfigPath = spec.CNIKubeconfigPath
+	}
+
+	if spec.LinkerdNamespace != "" {
+		s.LinkerdNamespace = spec.LinkerdNamespace
+	}
+
+	if spec.NamespaceUIDRangeAnnotation != "" {
+		s.NamespaceUIDRangeAnnotation = spec.NamespaceUIDRangeAnnotation
+	}
+
+	if spec.LinkerdProxyUIDOffset != nil {
+		s.LinkerdProxyUIDOffset = int(*spec.LinkerdProxyUIDOffset)
+	}
+
+	if spec.NetworkAttachmentDefinitionName != "" {
+		s.NetworkAttachmentDefinitionName = spec.NetworkAttachmentDefinitionName
+	}
+
+	if spec.AttachLabel != nil {
+		s.AttachLabel = *spec.AttachLabel
+	}
+
+	if spec.Mode != "" {
+		s.Mode = spec.Mode
+	}
+
+	if spec.CNIVersion != "" {
+		s.CNIVersion = spec.CNIVersion
+	}
+
+	if len(spec.CNISupportedVersions) != 0 {
+		s.CNISupportedVersions = spec.CNISupportedVersions
+	}
+
+	if spec.Chain != nil && len(spec.Chain.Plugins) != 0 {
+		s.Chain = newChain(spec.Chain)
+	}
+
+	if len(spec.Meshes) != 0 {
+		s.Meshes = make([]Mesh, 0, len(spec.Meshes))
+
+		for i := range spec.Meshes {
+			s.Meshes = append(s.Meshes, s.newMesh(&spec.Meshes[i]))
+		}
+	}
+
+	return s
+}
+
+// newChain converts the LinkerdMultusConfig plugin chain to Chain.
+func newChain(spec *multusv1alpha1.PluginChain) *Chain {
+	var chain = &Chain{
+		Plugins:         make([]json.RawMessage, 0, len(spec.Plugins)),
+		LinkerdPosition: -1,
+	}
+
+	for i := range spec.Plugins {
+		chain.Plugins = append(chain.Plugins, json.RawMessage(spec.Plugins[i].Raw))
+	}
+
+	if spec.LinkerdPosition != nil {
+		chain.LinkerdPosition = int(*spec.LinkerdPosition)
+	}
+
+	return chain
+}
+
+// newMesh converts the LinkerdMultusConfig mesh to Mesh.
+// The empty fields are taken from the settings.
+func (s Settings) newMesh(spec *multusv1alpha1.MeshInstance) Mesh {
+	var mesh = Mesh{
+		LinkerdNamespace:                spec.LinkerdNamespace,
+		CNINamespace:                    spec.CNINamespace,
+		CNIKubeconfigPath:               spec.CNIKubeconfigPath,
+		NetworkAttachmentDefinitionName: spec.NetworkAttachmentDefinitionName,
+	}
+
+	if mesh.CNINamespace == "" {
+		mesh.CNINamespace = s.CNINamespace
+	}
+
+	if mesh.CNIKubeconfigPath == "" {
+		mesh.CNIKubeconfigPath = s.CNIKubeconfigPath
+	}
+
+	if mesh.NetworkAttachmentDefinitionName == "" {
+		mesh.NetworkAttachmentDefinitionName = s.defaultNetworkAttachmentDefinitionName()
+	}
+
+	return mesh
+}
+
+// Loader reads the LinkerdMultusConfig resource and merges it with the defaults.
+type Loader struct {
+	// Client is used to get the LinkerdMultusConfig, it is expected to be a cached client.
+	Client client.Reader
+	// ConfigName is the name of the LinkerdMultusConfig resource.
+	ConfigName string
+	// Defaults are the settings given by the command-line flags.
+	Defaults Settings
+}
+
+// GetConfig returns the LinkerdMultusConfig resource or nil, if it does not exist.
+func (l *Loader) GetConfig(ctx context.Context) (*multusv1alpha1.LinkerdMultusConfig, error) {
+	var config = &multusv1alpha1.LinkerdMultusConfig{}
+
+	if err := l.Client.Get(ctx, types.NamespacedName{Name: l.ConfigName}, config); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("can not get LinkerdMultusConfig %s: %w", l.ConfigName, err)
+	}
+
+	return config, nil
+}
+
+// Load returns the effective settings. If the LinkerdMultusConfig resource
+// does not exist, the defaults are returned.
+func (l *Loader) Load(ctx context.Context) (Settings, error) {
+	config, err := l.GetConfig(ctx)
+	if err != nil {
+		return Settings{}, err
+	}
+
+	if config == nil {
+		return l.Defaults, nil
+	}
+
+	return l.Defaults.Merge(&config.Spec), nil
+}
@@ -0,0 +1,376 @@
+package settings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	multusv1alpha1 "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/api/v1alpha1"
+	k8s "github.com/ErmakovDmitriy/linkerd-multus-attach-operator/k8s"
+)
+
+var testDefaults = Settings{
+	CNINamespace:                "linkerd-cni",
+	CNIKubeconfigPath:           "/etc/cni/net.d/ZZZ-linkerd-cni-kubeconfig",
+	LinkerdNamespace:            "linkerd",
+	NamespaceUIDRangeAnnotation: "openshift.io/sa.scc.uid-range",
+	LinkerdProxyUIDOffset:       2102,
+	AttachLabel:                 "multus.linkerd.io/attach",
+}
+
+func TestMerge(t *testing.T) {
+	var (
+		offset     int32 = 10
+		emptyLabel       = ""
+	)
+
+	tests := []struct {
+		name     string
+		spec     *multusv1alpha1.LinkerdMultusConfigSpec
+		expected func(s Settings) Settings
+	}{
+		{
+			name:     "nil spec",
+			expected: func(s Settings) Settings { return s },
+		},
+		{
+			name:     "empty spec",
+			spec:     &multusv1alpha1.LinkerdMultusConfigSpec{},
+			expected: func(s Settings) Settings { return s },
+		},
+		{
+			name: "overridden fields",
+			spec: &multusv1alpha1.LinkerdMultusConfigSpec{
+				CNINamespace:                "cni",
+				CNIKubeconfigPath:           "/kubeconfig",
+				LinkerdNamespace:            "mesh",
+				NamespaceUIDRangeAnnotation: "uid-range",
+				LinkerdProxyUIDOffset:       &offset,
+			},
+			expected: func(s Settings) Settings {
+				s.CNINamespace = "cni"
+				s.CNIKubeconfigPath = "/kubeconfig"
+				s.LinkerdNamespace = "mesh"
+				s.NamespaceUIDRangeAnnotation = "uid-range"
+				s.LinkerdProxyUIDOffset = 10
+
+				return s
+			},
+		},
+		{
+			name: "empty attach label disables the label",
+			spec: &multusv1alpha1.LinkerdMultusConfigSpec{AttachLabel: &emptyLabel},
+			expected: func(s Settings) Settings {
+				s.AttachLabel = ""
+
+				return s
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := testDefaults.Merge(tt.spec)
+
+			if expected := tt.expected(testDefaults); !reflect.DeepEqual(got, expected) {
+				t.Errorf("Merge() = %+v, want %+v", got, expected)
+			}
+		})
+	}
+}
+
+func TestLoaderLoad(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := multusv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("AddToScheme() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		objects  []runtime.Object
+		expected string
+	}{
+		{
+			name:     "no LinkerdMultusConfig",
+			expected: testDefaults.LinkerdNamespace,
+		},
+		{
+			name: "LinkerdMultusConfig overrides the defaults",
+			objects: []runtime.Object{&multusv1alpha1.LinkerdMultusConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: multusv1alpha1.LinkerdMultusConfigNameDefault},
+				Spec:       multusv1alpha1.LinkerdMultusConfigSpec{LinkerdNamespace: "mesh"},
+			}},
+			expected: "mesh",
+		},
+		{
+			name: "other LinkerdMultusConfig is ignored",
+			objects: []runtime.Object{&multusv1alpha1.LinkerdMultusConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "other"},
+				Spec:       multusv1alpha1.LinkerdMultusConfigSpec{LinkerdNamespace: "mesh"},
+			}},
+			expected: testDefaults.LinkerdNamespace,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := &Loader{
+				Client:     fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(tt.objects...).Build(),
+				ConfigName: multusv1alpha1.LinkerdMultusConfigNameDefault,
+				Defaults:   testDefaults,
+			}
+
+			cfg, err := loader.Load(context.Background())
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			if cfg.LinkerdNamespace != tt.expected {
+				t.Errorf("Load() LinkerdNamespace = %q, want %q", cfg.LinkerdNamespace, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMergeMeshes(t *testing.T) {
+	cfg := testDefaults.Merge(&multusv1alpha1.LinkerdMultusConfigSpec{
+		NetworkAttachmentDefinitionName: "linkerd-cni-default",
+		Meshes: []multusv1alpha1.MeshInstance{
+			{LinkerdNamespace: "linkerd-a"},
+			{
+				LinkerdNamespace:                "linkerd-b",
+				CNINamespace:                    "linkerd-cni-b",
+				CNIKubeconfigPath:               "/b",
+				NetworkAttachmentDefinitionName: "linkerd-cni-b",
+			},
+		},
+	})
+
+	expected := []Mesh{
+		{
+			LinkerdNamespace:                "linkerd-a",
+			CNINamespace:                    testDefaults.CNINamespace,
+			CNIKubeconfigPath:               testDefaults.CNIKubeconfigPath,
+			NetworkAttachmentDefinitionName: "linkerd-cni-default",
+		},
+		{
+			LinkerdNamespace:                "linkerd-b",
+			CNINamespace:                    "linkerd-cni-b",
+			CNIKubeconfigPath:               "/b",
+			NetworkAttachmentDefinitionName: "linkerd-cni-b",
+		},
+	}
+
+	if got := cfg.GetMeshes(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("GetMeshes() = %+v, want %+v", got, expected)
+	}
+}
+
+func TestGetMeshesDefault(t *testing.T) {
+	expected := []Mesh{{
+		LinkerdNamespace:                testDefaults.LinkerdNamespace,
+		CNINamespace:                    testDefaults.CNINamespace,
+		CNIKubeconfigPath:               testDefaults.CNIKubeconfigPath,
+		NetworkAttachmentDefinitionName: k8s.MultusNetworkAttachmentDefinitionName,
+	}}
+
+	if got := testDefaults.GetMeshes(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("GetMeshes() = %+v, want %+v", got, expected)
+	}
+}
+
+func TestMeshForNamespace(t *testing.T) {
+	cfg := testDefaults.Merge(&multusv1alpha1.LinkerdMultusConfigSpec{
+		Meshes: []multusv1alpha1.MeshInstance{{LinkerdNamespace: "linkerd-a"}, {LinkerdNamespace: "linkerd-b"}},
+	})
+
+	tests := []struct {
+		name        string
+		namespace   string
+		labels      map[string]string
+		annotations map[string]string
+		expected    string
+	}{
+		{"default mesh", "app", nil, nil, "linkerd-a"},
+		{"control plane namespace", "linkerd-b", nil, nil, "linkerd-b"},
+		{"control plane namespace ignores the label", "linkerd-b",
+			map[string]string{k8s.LinkerdControlPlaneNamespaceLabel: "linkerd-a"}, nil, "linkerd-b"},
+		{"label", "app", map[string]string{k8s.LinkerdControlPlaneNamespaceLabel: "linkerd-b"}, nil, "linkerd-b"},
+		{"annotation", "app", nil, map[string]string{k8s.LinkerdControlPlaneNamespaceLabel: "linkerd-b"}, "linkerd-b"},
+		{"label wins over annotation", "app",
+			map[string]string{k8s.LinkerdControlPlaneNamespaceLabel: "linkerd-a"},
+			map[string]string{k8s.LinkerdControlPlaneNamespaceLabel: "linkerd-b"}, "linkerd-a"},
+		{"unknown mesh", "app", map[string]string{k8s.LinkerdControlPlaneNamespaceLabel: "linkerd-c"}, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        tt.namespace,
+				Labels:      tt.labels,
+				Annotations: tt.annotations,
+			}}
+
+			mesh := cfg.MeshForNamespace(ns)
+
+			switch {
+			case tt.expected == "" && mesh != nil:
+				t.Errorf("MeshForNamespace() = %q, want nil", mesh.LinkerdNamespace)
+			case tt.expected != "" && (mesh == nil || mesh.LinkerdNamespace != tt.expected):
+				t.Errorf("MeshForNamespace() = %+v, want %q", mesh, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNetworkAttachmentDefinitionNameFor(t *testing.T) {
+	cfg := testDefaults.Merge(&multusv1alpha1.LinkerdMultusConfigSpec{
+		Meshes: []multusv1alpha1.MeshInstance{
+			{LinkerdNamespace: "linkerd-a"},
+			{LinkerdNamespace: "linkerd-b", NetworkAttachmentDefinitionName: "linkerd-cni-b"},
+		},
+	})
+	meshes := cfg.GetMeshes()
+
+	tests := []struct {
+		name        string
+		mesh        *Mesh
+		annotations map[string]string
+		expected    string
+		expectedErr bool
+	}{
+		{name: "default name", mesh: &meshes[0], expected: k8s.MultusNetworkAttachmentDefinitionName},
+		{name: "mesh name", mesh: &meshes[1], expected: "linkerd-cni-b"},
+		{name: "empty annotation", mesh: &meshes[1],
+			annotations: map[string]string{k8s.NetworkAttachmentDefinitionNameAnnotation: ""}, expected: "linkerd-cni-b"},
+		{name: "annotation wins over the mesh name", mesh: &meshes[1],
+			annotations: map[string]string{k8s.NetworkAttachmentDefinitionNameAnnotation: "app.linkerd-cni"},
+			expected:    "app.linkerd-cni"},
+		{name: "invalid annotation", mesh: &meshes[0],
+			annotations: map[string]string{k8s.NetworkAttachmentDefinitionNameAnnotation: "Linkerd_CNI"}, expectedErr: true},
+		{name: "namespaced annotation", mesh: &meshes[0],
+			annotations: map[string]string{k8s.NetworkAttachmentDefinitionNameAnnotation: "app/linkerd-cni"}, expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app", Annotations: tt.annotations}}
+
+			got, err := tt.mesh.NetworkAttachmentDefinitionNameFor(ns)
+			if tt.expectedErr != errors.Is(err, ErrInvalidNetworkAttachmentDefinitionName) {
+				t.Fatalf("NetworkAttachmentDefinitionNameFor() error = %v, want error %v", err, tt.expectedErr)
+			}
+
+			if got != tt.expected {
+				t.Errorf("NetworkAttachmentDefinitionNameFor() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseNetworkAttachmentDefinitionReference(t *testing.T) {
+	tests := []struct {
+		name              string
+		reference         string
+		expectedNamespace string
+		expectedName      string
+		expectedErr       bool
+	}{
+		{name: "name", reference: "linkerd-cni", expectedName: "linkerd-cni"},
+		{name: "namespace and name", reference: "app/linkerd-cni", expectedNamespace: "app", expectedName: "linkerd-cni"},
+		{name: "name with dots", reference: "app/linkerd.cni", expectedNamespace: "app", expectedName: "linkerd.cni"},
+		{name: "empty reference", reference: "", expectedErr: true},
+		{name: "invalid name", reference: "Linkerd_CNI", expectedErr: true},
+		{name: "invalid namespace", reference: "app.web/linkerd-cni", expectedErr: true},
+		{name: "empty namespace", reference: "/linkerd-cni", expectedErr: true},
+		{name: "empty name", reference: "app/", expectedErr: true},
+		{name: "several slashes", reference: "app/linkerd/cni", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace, name, err := ParseNetworkAttachmentDefinitionReference(tt.reference)
+			if tt.expectedErr != errors.Is(err, ErrInvalidNetworkAttachmentDefinitionName) {
+				t.Fatalf("ParseNetworkAttachmentDefinitionReference() error = %v, want error %v", err, tt.expectedErr)
+			}
+
+			if namespace != tt.expectedNamespace || name != tt.expectedName {
+				t.Errorf("ParseNetworkAttachmentDefinitionReference() = %q, %q, want %q, %q",
+					namespace, name, tt.expectedNamespace, tt.expectedName)
+			}
+		})
+	}
+}
+
+func TestMergeChain(t *testing.T) {
+	var position int32 = 1
+
+	plugins := []runtime.RawExtension{{Raw: []byte(`{"type":"sbr"}`)}}
+
+	tests := []struct {
+		name     string
+		chain    *multusv1alpha1.PluginChain
+		expected *Chain
+	}{
+		{
+			name: "no chain",
+		},
+		{
+			name:  "empty chain",
+			chain: &multusv1alpha1.PluginChain{},
+		},
+		{
+			name:     "default Linkerd position is the end of the chain",
+			chain:    &multusv1alpha1.PluginChain{Plugins: plugins},
+			expected: &Chain{Plugins: []json.RawMessage{json.RawMessage(`{"type":"sbr"}`)}, LinkerdPosition: -1},
+		},
+		{
+			name:     "Linkerd position",
+			chain:    &multusv1alpha1.PluginChain{Plugins: plugins, LinkerdPosition: &position},
+			expected: &Chain{Plugins: []json.RawMessage{json.RawMessage(`{"type":"sbr"}`)}, LinkerdPosition: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := testDefaults.Merge(&multusv1alpha1.LinkerdMultusConfigSpec{Chain: tt.chain}).Chain
+
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Merge() Chain = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseCNIVersions(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expected    []string
+		expectedErr bool
+	}{
+		{name: "empty value"},
+		{name: "versions", value: "0.3.0, 0.4.0,1.0.0", expected: []string{"0.3.0", "0.4.0", "1.0.0"}},
+		{name: "malformed version", value: "0.3.0,latest", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCNIVersions(tt.value)
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("ParseCNIVersions() error = %v, want error %v", err, tt.expectedErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ParseCNIVersions() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}